@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/your-username/dead-link-checker/internal"
+)
+
+// applyAuthFlags adds the credentials and cookie jar from the auth flags to
+// the client options. Credentials without a host, including sensitive
+// --header values, go only to the --auth-host hosts, or the start URL's
+// host, so they never leak to external sites;
+// without a start host they need one or the other. The loaded cookie
+// values are returned so they can be redacted.
+func applyAuthFlags(cmd *cobra.Command, startHost string, opts *internal.ClientOptions) ([]string, error) {
+	authHosts, err := cmd.Flags().GetStringSlice("auth-host")
+	if err != nil {
+		fmt.Println("Error retreiving auth-host")
+	}
+	if len(authHosts) == 0 && startHost != "" {
+		authHosts = []string{startHost}
+	}
+
+	// Sensitive headers are credentials too
+	headers, credentials := internal.ScopeHeaders(opts.Headers, authHosts)
+	if len(headers) < len(opts.Headers) && len(credentials) == 0 {
+		return nil, usageError("--header credentials such as Authorization need --auth-host")
+	}
+	opts.Headers = headers
+	opts.Credentials = append(opts.Credentials, credentials...)
+
+	basicAuth, err := cmd.Flags().GetStringArray("basic-auth")
+	if err != nil {
+		fmt.Println("Error retreiving basic-auth")
+	}
+	for _, value := range basicAuth {
+		credentials, err := internal.ParseBasicAuth(value, authHosts)
+		if err != nil {
+			return nil, usageError("%s", err)
+		}
+		if len(credentials) == 0 {
+			return nil, usageError("--basic-auth needs a host= prefix or --auth-host")
+		}
+		opts.Credentials = append(opts.Credentials, credentials...)
+	}
+	bearerTokens, err := cmd.Flags().GetStringArray("bearer-token")
+	if err != nil {
+		fmt.Println("Error retreiving bearer-token")
+	}
+	for _, value := range bearerTokens {
+		credentials, err := internal.ParseBearerToken(value, authHosts)
+		if err != nil {
+			return nil, usageError("%s", err)
+		}
+		if len(credentials) == 0 {
+			return nil, usageError("--bearer-token needs a host= prefix or --auth-host")
+		}
+		opts.Credentials = append(opts.Credentials, credentials...)
+	}
+
+	cookieFile, err := cmd.Flags().GetString("cookie-jar")
+	if err != nil {
+		fmt.Println("Error retreiving cookie-jar")
+	}
+	if cookieFile == "" {
+		return nil, nil
+	}
+	file, err := os.Open(cookieFile)
+	if err != nil {
+		return nil, usageError("%s", err)
+	}
+	defer file.Close()
+
+	jar, cookies, err := internal.LoadCookieJar(file)
+	if err != nil {
+		return nil, usageError("%s", err)
+	}
+	opts.CookieJar = jar
+	var values []string
+	for _, cookie := range cookies {
+		values = append(values, cookie.Value)
+	}
+	return values, nil
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/your-username/dead-link-checker/internal"
+)
+
+// cacheCmd groups the result cache subcommands
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Work with the result cache",
+	Long: `With --cache, check and check-files keep the results of external links
+between runs, so links checked recently are not requested again. Alive
+results are reused for --cache-ttl and dead ones for --cache-dead-ttl;
+after that a link is checked again, with a conditional request when the
+server sent an ETag or Last-Modified header. Links on the site being
+checked are always requested, and nothing is cached when credentials or
+cookies are sent.`,
+}
+
+// cacheClearCmd deletes the cache file
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete every cached result",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := cachePath(cmd)
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		if err := internal.ClearCache(path); err != nil {
+			return err
+		}
+		fmt.Println("Cleared " + path)
+		return nil
+	},
+}
+
+// cachePath returns --cache-file, or the default cache file
+func cachePath(cmd *cobra.Command) (string, error) {
+	path, err := cmd.Flags().GetString("cache-file")
+	if err != nil {
+		fmt.Println("Error retreiving cache-file")
+	}
+	if path != "" {
+		return path, nil
+	}
+	path, err = internal.DefaultCachePath()
+	if err != nil {
+		return "", usageError("no user cache directory, set --cache-file: %s", err)
+	}
+	return path, nil
+}
+
+// readCache opens the result cache with --cache, or returns nil
+func readCache(cmd *cobra.Command) (*internal.ResultCache, error) {
+	useCache, err := cmd.Flags().GetBool("cache")
+	if err != nil {
+		fmt.Println("Error retreiving cache")
+	}
+	cacheOpts := internal.DefaultCacheOptions()
+	cacheOpts.AliveTTL, err = cmd.Flags().GetDuration("cache-ttl")
+	if err != nil {
+		fmt.Println("Error retreiving cache-ttl")
+	}
+	cacheOpts.DeadTTL, err = cmd.Flags().GetDuration("cache-dead-ttl")
+	if err != nil {
+		fmt.Println("Error retreiving cache-dead-ttl")
+	}
+	if cacheOpts.AliveTTL < 0 || cacheOpts.DeadTTL < 0 {
+		return nil, usageError("--cache-ttl and --cache-dead-ttl must not be negative")
+	}
+	if !useCache {
+		return nil, nil
+	}
+	path, err := cachePath(cmd)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := internal.OpenCache(path, cacheOpts)
+	if err != nil {
+		return nil, usageError("%s", err)
+	}
+	return cache, nil
+}
+
+// saveCache writes the cache back. A cache that cannot be saved only costs
+// the next run some requests, so it is reported without failing this one.
+func saveCache(cache *internal.ResultCache) {
+	if err := cache.Save(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error saving cache "+cache.Path()+":", err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheClearCmd.Flags().String("cache-file", "", "Cache file to delete (default results.json in the user cache directory)")
+}
@@ -3,12 +3,17 @@ package cmd
 import (
 	"fmt"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/your-username/dead-link-checker/internal"
+	neturl "net/url"
+	"os"
+	"slices"
+	"strings"
 )
 
 // checkCmd represents the check command
 var checkCmd = &cobra.Command{
-	Use:   "check <url>",
+	Use:   "check <url|directory>",
 	Short: "Crawl a website and detect broken links",
 	Long: `Crawl a website starting from the specified URL and detect all broken links.
 
@@ -18,7 +23,18 @@ The crawler will:
 • Report broken links with their status codes
 • Preserve relative paths for complete site coverage
 
-Use the --depth flag to control how deep the crawler goes into your site.`,
+Use the --depth flag to control how deep the crawler goes into your site.
+
+Given a directory or file:// URL instead, such as the output of a static
+site generator, every HTML file in it is read from disk. Links under
+--base-url are checked against the files in the directory, serving a
+directory's index.html, and only the other links are requested over HTTP.
+
+Exit codes:
+  0  no dead links matched a --fail-on rule
+  1  dead links matched a --fail-on rule
+  2  invalid flags, arguments or configuration
+  3  the start URL itself could not be fetched`,
 	Example: `  # Check homepage and one level deep
   dead-link-checker check https://example.com -d 1
   
@@ -26,29 +42,430 @@ Use the --depth flag to control how deep the crawler goes into your site.`,
   dead-link-checker check https://example.com
   
   # Deep crawl (5 levels)
-  dead-link-checker check https://mysite.com --depth 5`,
+  dead-link-checker check https://mysite.com --depth 5
+
+  # Check a Hugo build before deploying it
+  dead-link-checker check ./public --base-url https://docs.example.com/
+
+  # Crawl only the docs, skipping the changelog
+  dead-link-checker check https://example.com/docs/ --path-prefix /docs/ --exclude "/docs/changelog/*"
+
+  # Fail a CI job only on broken internal links or many broken external ones
+  dead-link-checker check https://example.com --fail-on internal --fail-on "external>5"`,
 	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Get url and make sure it can be crawled, unless it names a local build
+		target := args[0]
+		url := target
+		dir, local, err := localDir(target)
+		if err != nil {
+			return err
+		}
+		startURL, err := neturl.Parse(url)
+		if !local && (err != nil || (startURL.Scheme != "http" && startURL.Scheme != "https") || startURL.Host == "") {
+			return usageError("start URL %q must be an absolute http or https URL, a directory or a file:// URL", url)
+		}
+		// Fill in flags not given on the command line from the config file
+		configFile, err := cmd.Flags().GetString("config")
+		if err != nil {
+			fmt.Println("Error retreiving config")
+		}
+		configFile, err = findConfigFile(configFile)
+		if err != nil {
+			return err
+		}
+		if configFile != "" {
+			if err := applyConfig(cmd.Flags(), configFile); err != nil {
+				return err
+			}
+		}
+		// A local build stands in for the site served at its base url
+		var site *internal.LocalSite
+		if local {
+			site, err = readLocalSite(cmd, dir)
+			if err != nil {
+				return err
+			}
+			url = site.BaseURL()
+			startURL, _ = neturl.Parse(url)
+		}
+
 		// Get flag depth
-		depth, err := cmd.Flags().GetInt("depth")
+		crawlOpts := internal.DefaultCrawlOptions()
+		crawlOpts.MaxDepth, err = cmd.Flags().GetInt("depth")
 		if err != nil {
 			fmt.Println("Error retreiving depth")
 		}
-		// Get url and pass into crawler then retreive deadlinks
-		url := args[0]
-		fmt.Println("Checking " + url)
-		siteURLs := internal.CrawlSite(url, depth)
-		fmt.Println("Collecting dead URLs:")
-		deadURLs := internal.GetDeadLinks(&siteURLs)
-		for _, deadURL := range deadURLs {
-			fmt.Println(deadURL)
+		crawlOpts.Workers, err = cmd.Flags().GetInt("workers")
+		if err != nil {
+			fmt.Println("Error retreiving workers")
 		}
-		fmt.Println("check called")
+		crawlOpts.IgnoreRobots, err = cmd.Flags().GetBool("ignore-robots")
+		if err != nil {
+			fmt.Println("Error retreiving ignore-robots")
+		}
+		crawlOpts.RobotsAgent, err = cmd.Flags().GetString("robots-agent")
+		if err != nil {
+			fmt.Println("Error retreiving robots-agent")
+		}
+		crawlOpts.UseSitemaps, err = cmd.Flags().GetBool("sitemap")
+		if err != nil {
+			fmt.Println("Error retreiving sitemap")
+		}
+		crawlOpts.SitemapURLs, err = cmd.Flags().GetStringSlice("sitemap-url")
+		if err != nil {
+			fmt.Println("Error retreiving sitemap-url")
+		}
+		crawlOpts.Kinds, err = readKinds(cmd)
+		if err != nil {
+			return err
+		}
+		// One normalizer shared by crawling and checking
+		normalizer, err := readNormalizer(cmd)
+		if err != nil {
+			return err
+		}
+		crawlOpts.Normalizer = normalizer
+		// Which pages belong to the site
+		scope, err := internal.NewScope(url, readScopeOptions(cmd))
+		if err != nil {
+			return usageError("%s", err)
+		}
+		crawlOpts.Scope = scope
+		// One HTTP client and rate limiter shared by crawling and checking
+		client, redactor, err := newClient(cmd, startURL.Hostname())
+		if err != nil {
+			return err
+		}
+		crawlOpts.Client = client
+		limiter, err := newLimiter(cmd)
+		if err != nil {
+			return err
+		}
+		crawlOpts.Limiter = limiter
+		checkOpts, err := readCheckOptions(cmd, client, limiter)
+		if err != nil {
+			return err
+		}
+		checkOpts.Normalizer = normalizer
+		checkOpts.Local = site
+		checkOpts.Scope = scope
+		format, policy, err := readOutputFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Everything past here is a check failure, not a usage mistake
+		cmd.SilenceUsage = true
+
+		if format == "text" {
+			fmt.Println("Checking " + redactor.URL(target))
+		}
+		// Pass url into crawler then retreive deadlinks
+		var crawl *internal.CrawlResult
+		if site != nil {
+			crawl = internal.CrawlLocal(site, crawlOpts)
+		} else {
+			crawl = internal.CrawlSite(url, crawlOpts)
+		}
+		results := checkAndReport(os.Stdout, format, redactor.URL(target), crawl, checkOpts, redactor)
+
+		if crawl.StartError != "" {
+			return &exitError{code: exitStartFailed, err: fmt.Errorf("could not fetch start URL %s", redactor.String(target+": "+crawl.StartError))}
+		}
+		return failOnError(policy, results, scope)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(checkCmd)
+	addCheckFlags(checkCmd.Flags())
+	// Config file flag, the only one a config file cannot set
+	checkCmd.Flags().String("config", "", "Config file to read flag values from (default "+internal.ConfigFileName+" in the working directory, if present)")
+}
+
+// addCheckFlags defines the flags of the check command. Config files use
+// the same names, so validation builds a fresh set from here too.
+func addCheckFlags(flags *pflag.FlagSet) {
+	addCrawlFlags(flags)
+	addLinkFlags(flags)
+}
+
+// addCrawlFlags defines the flags deciding which pages are crawled
+func addCrawlFlags(flags *pflag.FlagSet) {
 	// Depth flag
-	checkCmd.Flags().IntP("depth", "d", 2, "Maximum crawl depth")
+	flags.IntP("depth", "d", 2, "Maximum crawl depth")
+	crawlDefaults := internal.DefaultCrawlOptions()
+	flags.IntP("workers", "w", crawlDefaults.Workers, "Number of pages fetched at once while crawling")
+	// Robots flags
+	flags.Bool("ignore-robots", false, "Crawl and check the site's URLs even when its robots.txt disallows them")
+	flags.String("robots-agent", crawlDefaults.RobotsAgent, "User-agent token matched against robots.txt rules")
+	// Sitemap flags
+	flags.Bool("sitemap", false, "Also crawl pages listed in the site's sitemaps (discovered from robots.txt or /sitemap.xml)")
+	flags.StringSlice("sitemap-url", nil, "Sitemap or sitemap index to read instead of discovering one (repeatable)")
+	// Local build flag
+	flags.String("base-url", internal.DefaultLocalBaseURL, "URL a local directory is served at; links under it are checked on disk")
+	// Scope flags
+	flags.StringArray("include", nil, "Only crawl pages matching this glob, or regular expression prefixed with re: (repeatable)")
+	flags.StringArray("exclude", nil, "Never crawl pages matching this glob, or regular expression prefixed with re: (repeatable)")
+	flags.Bool("subdomains", false, "Treat subdomains of the start host, and its bare or www. form, as internal")
+	flags.StringSlice("path-prefix", nil, "Only crawl pages under this path, such as /docs/ (repeatable)")
+	flags.StringSlice("internal-host", nil, "Another host to treat as internal; *.example.com matches its subdomains (repeatable)")
+	// Normalization flags
+	normalizeDefaults := internal.DefaultNormalizeOptions()
+	flags.StringSlice("strip-param", normalizeDefaults.StripParams, "Query parameters removed before URLs are compared; a trailing * matches a prefix (repeatable)")
+	flags.Bool("sort-query", normalizeDefaults.SortQuery, "Sort query parameters so their order does not make URLs different")
+	flags.String("trailing-slash", normalizeDefaults.TrailingSlash, "Trailing slash policy: "+strings.Join(internal.SlashPolicies, ", "))
+}
+
+// addLinkFlags defines the flags controlling how links are checked and
+// reported, shared by check and check-files
+func addLinkFlags(flags *pflag.FlagSet) {
+	// Link kind filter
+	flags.StringSlice("kind", nil, "Only check links of these kinds: "+strings.Join(internal.LinkKinds, ", ")+" (repeatable)")
+	// Concurrency flags
+	defaults := internal.DefaultCheckOptions()
+	flags.IntP("concurrency", "c", defaults.Concurrency, "Maximum number of links checked at once")
+	flags.Int("per-host", defaults.PerHost, "Maximum number of links checked at once on a single host")
+	flags.String("method", defaults.Method, "Request method: auto (HEAD, falling back to GET), head or get")
+	// HTTP client flags
+	clientDefaults := internal.DefaultClientOptions()
+	flags.Duration("connect-timeout", clientDefaults.ConnectTimeout, "Time allowed to connect, including the TLS handshake")
+	flags.Duration("response-timeout", clientDefaults.ResponseTimeout, "Time allowed for response headers once a request is sent")
+	flags.Duration("timeout", clientDefaults.Timeout, "Time allowed for a whole request, including downloading pages")
+	flags.String("user-agent", clientDefaults.UserAgent, "User-Agent header sent with every request")
+	flags.StringArray("header", nil, "Extra request header as \"Name: value\"; Authorization and other credentials only go to the start host or --auth-host hosts (repeatable)")
+	flags.String("proxy", "", "Proxy URL for all requests (default from HTTP_PROXY, HTTPS_PROXY and NO_PROXY)")
+	flags.Int("max-redirects", clientDefaults.MaxRedirects, "Redirects followed before a link is reported dead")
+	flags.Bool("soft-404", false, "Download alive pages to spot error pages served with a success status")
+	flags.Int("long-chain", defaults.LongChain, "Report working links that go through more redirects than this, 0 to never report them")
+	// Authentication flags
+	flags.StringArray("basic-auth", nil, "Basic auth credentials as \"[host=]user:password\" (repeatable)")
+	flags.StringArray("bearer-token", nil, "Bearer token as \"[host=]token\" (repeatable)")
+	flags.StringSlice("auth-host", nil, "Hosts that credentials without a host= prefix are sent to (default the start URL's host)")
+	flags.String("cookie-jar", "", "Netscape format cookies.txt file whose cookies are sent to their own domains")
+	// Politeness flags
+	flags.Float64("rate", 0, "Maximum requests per second to any one host, 0 for no limit")
+	flags.Duration("delay", 0, "Minimum wait between requests to the same host")
+	// Ignore flags
+	flags.StringArray("ignore", nil, "Ignore links matching a pattern, as \"pattern [status...]\"; with statuses only those failures are ignored (repeatable)")
+	flags.String("ignore-file", "", "File of ignore rules, one \"pattern [status...]\" per line")
+	// Retry flags
+	flags.Int("retries", defaults.Retry.MaxRetries, "Times to retry a link after a timeout, connection error, 429, 502, 503 or 504")
+	flags.Duration("retry-delay", defaults.Retry.BaseDelay, "Wait before the first retry, doubled for each retry after it")
+	flags.Duration("retry-max-delay", defaults.Retry.MaxDelay, "Longest wait between retries; a longer Retry-After gives up instead")
+	// Cache flags
+	cacheDefaults := internal.DefaultCacheOptions()
+	flags.Bool("cache", false, "Reuse the results of external links from earlier runs instead of checking them again")
+	flags.String("cache-file", "", "File results are cached in with --cache (default results.json in the user cache directory)")
+	flags.Duration("cache-ttl", cacheDefaults.AliveTTL, "How long an alive result is reused before the link is checked again")
+	flags.Duration("cache-dead-ttl", cacheDefaults.DeadTTL, "How long a dead result is reused before the link is checked again")
+	// Output flag
+	flags.StringP("format", "f", "text", "Output format: "+strings.Join(outputFormats, ", "))
+	// Exit code flag
+	flags.StringSlice("fail-on", []string{"any"}, "Exit with code 1 when dead links match any of these rules: any, internal, external, a status such as 404 or 5xx, an error category, or none; add >N to allow up to N matches (repeatable)")
+}
+
+// readKinds reads and validates the link kinds to check
+func readKinds(cmd *cobra.Command) ([]string, error) {
+	kinds, err := cmd.Flags().GetStringSlice("kind")
+	if err != nil {
+		fmt.Println("Error retreiving kind")
+	}
+	for _, kind := range kinds {
+		if !slices.Contains(internal.LinkKinds, kind) {
+			return nil, usageError("unknown link kind %q, expected one of: %s", kind, strings.Join(internal.LinkKinds, ", "))
+		}
+	}
+	return kinds, nil
+}
+
+// readNormalizer builds the url normalizer from the flags
+func readNormalizer(cmd *cobra.Command) (*internal.Normalizer, error) {
+	normalizeOpts := internal.DefaultNormalizeOptions()
+	var err error
+	normalizeOpts.StripParams, err = cmd.Flags().GetStringSlice("strip-param")
+	if err != nil {
+		fmt.Println("Error retreiving strip-param")
+	}
+	normalizeOpts.SortQuery, err = cmd.Flags().GetBool("sort-query")
+	if err != nil {
+		fmt.Println("Error retreiving sort-query")
+	}
+	normalizeOpts.TrailingSlash, err = cmd.Flags().GetString("trailing-slash")
+	if err != nil {
+		fmt.Println("Error retreiving trailing-slash")
+	}
+	if !slices.Contains(internal.SlashPolicies, normalizeOpts.TrailingSlash) {
+		return nil, usageError("unknown trailing slash policy %q, expected one of: %s", normalizeOpts.TrailingSlash, strings.Join(internal.SlashPolicies, ", "))
+	}
+	return internal.NewNormalizer(normalizeOpts), nil
+}
+
+// readScopeOptions reads the flags deciding which pages belong to the site
+func readScopeOptions(cmd *cobra.Command) internal.ScopeOptions {
+	var scopeOpts internal.ScopeOptions
+	var err error
+	scopeOpts.Include, err = cmd.Flags().GetStringArray("include")
+	if err != nil {
+		fmt.Println("Error retreiving include")
+	}
+	scopeOpts.Exclude, err = cmd.Flags().GetStringArray("exclude")
+	if err != nil {
+		fmt.Println("Error retreiving exclude")
+	}
+	scopeOpts.IncludeSubdomains, err = cmd.Flags().GetBool("subdomains")
+	if err != nil {
+		fmt.Println("Error retreiving subdomains")
+	}
+	scopeOpts.PathPrefixes, err = cmd.Flags().GetStringSlice("path-prefix")
+	if err != nil {
+		fmt.Println("Error retreiving path-prefix")
+	}
+	scopeOpts.ExtraHosts, err = cmd.Flags().GetStringSlice("internal-host")
+	if err != nil {
+		fmt.Println("Error retreiving internal-host")
+	}
+	return scopeOpts
+}
+
+// newClient builds the HTTP client from the flags, and a redactor that
+// keeps its credentials out of everything printed
+func newClient(cmd *cobra.Command, startHost string) (*internal.Client, *internal.Redactor, error) {
+	clientOpts := internal.DefaultClientOptions()
+	var err error
+	clientOpts.ConnectTimeout, err = cmd.Flags().GetDuration("connect-timeout")
+	if err != nil {
+		fmt.Println("Error retreiving connect-timeout")
+	}
+	clientOpts.ResponseTimeout, err = cmd.Flags().GetDuration("response-timeout")
+	if err != nil {
+		fmt.Println("Error retreiving response-timeout")
+	}
+	clientOpts.Timeout, err = cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		fmt.Println("Error retreiving timeout")
+	}
+	clientOpts.UserAgent, err = cmd.Flags().GetString("user-agent")
+	if err != nil {
+		fmt.Println("Error retreiving user-agent")
+	}
+	headers, err := cmd.Flags().GetStringArray("header")
+	if err != nil {
+		fmt.Println("Error retreiving header")
+	}
+	clientOpts.Headers, err = internal.ParseHeaders(headers)
+	if err != nil {
+		return nil, nil, usageError("%s", err)
+	}
+	clientOpts.Proxy, err = cmd.Flags().GetString("proxy")
+	if err != nil {
+		fmt.Println("Error retreiving proxy")
+	}
+	clientOpts.MaxRedirects, err = cmd.Flags().GetInt("max-redirects")
+	if err != nil {
+		fmt.Println("Error retreiving max-redirects")
+	}
+	cookieSecrets, err := applyAuthFlags(cmd, startHost, &clientOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := internal.NewClient(clientOpts)
+	if err != nil {
+		return nil, nil, usageError("%s", err)
+	}
+	return client, internal.NewRedactor(append(clientOpts.Secrets(), cookieSecrets...)...), nil
+}
+
+// newLimiter builds the per-host rate limiter from the flags
+func newLimiter(cmd *cobra.Command) (*internal.RateLimiter, error) {
+	rate, err := cmd.Flags().GetFloat64("rate")
+	if err != nil {
+		fmt.Println("Error retreiving rate")
+	}
+	delay, err := cmd.Flags().GetDuration("delay")
+	if err != nil {
+		fmt.Println("Error retreiving delay")
+	}
+	if rate < 0 || delay < 0 {
+		return nil, usageError("--rate and --delay must not be negative")
+	}
+	return internal.NewRateLimiter(rate, delay), nil
+}
+
+// readCheckOptions reads the flags controlling how links are checked
+func readCheckOptions(cmd *cobra.Command, client *internal.Client, limiter *internal.RateLimiter) (internal.CheckOptions, error) {
+	// Get concurrency limits for the checker
+	checkOpts := internal.DefaultCheckOptions()
+	var err error
+	checkOpts.Concurrency, err = cmd.Flags().GetInt("concurrency")
+	if err != nil {
+		fmt.Println("Error retreiving concurrency")
+	}
+	checkOpts.PerHost, err = cmd.Flags().GetInt("per-host")
+	if err != nil {
+		fmt.Println("Error retreiving per-host")
+	}
+	checkOpts.Limiter = limiter
+	checkOpts.Client = client
+	checkOpts.Method, err = cmd.Flags().GetString("method")
+	if err != nil {
+		fmt.Println("Error retreiving method")
+	}
+	checkOpts.Retry.MaxRetries, err = cmd.Flags().GetInt("retries")
+	if err != nil {
+		fmt.Println("Error retreiving retries")
+	}
+	checkOpts.Retry.BaseDelay, err = cmd.Flags().GetDuration("retry-delay")
+	if err != nil {
+		fmt.Println("Error retreiving retry-delay")
+	}
+	checkOpts.Retry.MaxDelay, err = cmd.Flags().GetDuration("retry-max-delay")
+	if err != nil {
+		fmt.Println("Error retreiving retry-max-delay")
+	}
+	if !slices.Contains(internal.CheckMethods, checkOpts.Method) {
+		return checkOpts, usageError("unknown method %q, expected one of: %s", checkOpts.Method, strings.Join(internal.CheckMethods, ", "))
+	}
+	checkOpts.LongChain, err = cmd.Flags().GetInt("long-chain")
+	if err != nil {
+		fmt.Println("Error retreiving long-chain")
+	}
+	soft404, err := cmd.Flags().GetBool("soft-404")
+	if err != nil {
+		fmt.Println("Error retreiving soft-404")
+	}
+	if soft404 {
+		checkOpts.Soft404 = internal.NewSoft404Detector(client, limiter)
+	}
+	checkOpts.Ignore, err = readIgnoreFlags(cmd)
+	if err != nil {
+		return checkOpts, err
+	}
+	checkOpts.Cache, err = readCache(cmd)
+	if err != nil {
+		return checkOpts, err
+	}
+	return checkOpts, nil
+}
+
+// readOutputFlags reads the output format and the rules deciding when dead
+// links fail the run
+func readOutputFlags(cmd *cobra.Command) (string, internal.FailPolicy, error) {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		fmt.Println("Error retreiving format")
+	}
+	if !slices.Contains(outputFormats, format) {
+		return "", nil, usageError("unknown format %q, expected one of: %s", format, strings.Join(outputFormats, ", "))
+	}
+	failOn, err := cmd.Flags().GetStringSlice("fail-on")
+	if err != nil {
+		fmt.Println("Error retreiving fail-on")
+	}
+	policy, err := internal.ParseFailPolicy(failOn)
+	if err != nil {
+		return "", nil, usageError("%s", err)
+	}
+	return format, policy, nil
 }
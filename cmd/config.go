@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/your-username/dead-link-checker/internal"
+)
+
+// configCmd groups the config file subcommands
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Work with config files",
+	Long: `A config file holds check flag values so a long invocation can be shared.
+It is a JSON object whose keys are the long names of the check flags:
+
+  {
+    "depth": 3,
+    "subdomains": true,
+    "exclude": ["/blog/*"],
+    "ignore": ["https://www.linkedin.com/*", "* 999"],
+    "timeout": "20s",
+    "fail-on": ["internal", "external>5"]
+  }
+
+check reads ` + internal.ConfigFileName + ` from the working directory, or the file given to
+--config. Flags given on the command line override the file.`,
+}
+
+// configValidateCmd checks a config file without running a check
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Report unknown keys and invalid values in a config file",
+	Example: `  # Validate ` + internal.ConfigFileName + ` in the working directory
+  dead-link-checker config validate
+
+  # Validate another file
+  dead-link-checker config validate ci/deadlinks.json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		explicit := ""
+		if len(args) == 1 {
+			explicit = args[0]
+		}
+		path, err := findConfigFile(explicit)
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			return usageError("no %s in the working directory", internal.ConfigFileName)
+		}
+		cmd.SilenceUsage = true
+
+		// A fresh check command, so nothing is set on the real one
+		check := &cobra.Command{Use: "check"}
+		addCheckFlags(check.Flags())
+		if err := applyConfig(check.Flags(), path); err != nil {
+			return err
+		}
+		if problems := checkConfigValues(check); len(problems) > 0 {
+			return usageError("%s:\n  %s", path, strings.Join(problems, "\n  "))
+		}
+		fmt.Println(path + " is valid")
+		return nil
+	},
+}
+
+// configPathKeys are the keys holding file paths, resolved relative to the
+// config file rather than the working directory
+var configPathKeys = map[string]bool{
+	"ignore-file": true,
+	"cookie-jar":  true,
+	"cache-file":  true,
+}
+
+// findConfigFile returns the explicit path when given, otherwise the config
+// file in the working directory, or "" when there is none
+func findConfigFile(explicit string) (string, error) {
+	if explicit != "" {
+		if _, err := os.Stat(explicit); err != nil {
+			return "", usageError("%s", err)
+		}
+		return explicit, nil
+	}
+	if _, err := os.Stat(internal.ConfigFileName); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", usageError("%s", err)
+	}
+	return internal.ConfigFileName, nil
+}
+
+// applyConfig sets every flag named in the config file that was not given
+// on the command line. Every unknown key and invalid value is reported,
+// not just the first.
+func applyConfig(flags *pflag.FlagSet, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return usageError("%s", err)
+	}
+	defer file.Close()
+
+	config, err := internal.LoadConfig(file)
+	if err != nil {
+		return usageError("%s: %s", path, err)
+	}
+
+	var problems []string
+	for _, key := range config.Keys() {
+		flag := flags.Lookup(key)
+		if flag == nil {
+			problems = append(problems, fmt.Sprintf("unknown key %q", key))
+			continue
+		}
+		// Command line flags win
+		if flag.Changed {
+			continue
+		}
+
+		values := config.Values[key]
+		if configPathKeys[key] && len(values) == 1 && values[0] != "" && !filepath.IsAbs(values[0]) {
+			values = []string{filepath.Join(filepath.Dir(path), values[0])}
+		}
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			if err := slice.Replace(values); err != nil {
+				problems = append(problems, fmt.Sprintf("key %q: %v", key, err))
+			}
+			continue
+		}
+		if config.IsList(key) || len(values) != 1 {
+			problems = append(problems, fmt.Sprintf("key %q takes a single value, not a list", key))
+			continue
+		}
+		if err := flag.Value.Set(values[0]); err != nil {
+			problems = append(problems, fmt.Sprintf("key %q: invalid value %q for a %s", key, values[0], flag.Value.Type()))
+		}
+	}
+	if len(problems) > 0 {
+		return usageError("%s:\n  %s", path, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// checkConfigValues runs the value checks check makes before crawling, on
+// the flags of cmd, so a file that check would reject fails validation
+// too. Every problem is returned, not just the first.
+func checkConfigValues(cmd *cobra.Command) []string {
+	var problems []string
+	// The readers stop at their first problem, so the ones they would
+	// miss are also checked on their own; each is reported once
+	report := func(err error) {
+		if err != nil && !slices.Contains(problems, err.Error()) {
+			problems = append(problems, err.Error())
+		}
+	}
+	_, err := readKinds(cmd)
+	report(err)
+	_, err = readNormalizer(cmd)
+	report(err)
+	// Only the patterns can fail, so any start url will do
+	_, err = internal.NewScope("", readScopeOptions(cmd))
+	report(err)
+	headers, err := cmd.Flags().GetStringArray("header")
+	if err != nil {
+		fmt.Println("Error retreiving header")
+	}
+	_, err = internal.ParseHeaders(headers)
+	report(err)
+	_, err = newLimiter(cmd)
+	report(err)
+	_, err = readCheckOptions(cmd, nil, nil)
+	report(err)
+	_, err = readIgnoreFlags(cmd)
+	report(err)
+	_, err = readCache(cmd)
+	report(err)
+	_, _, err = readOutputFlags(cmd)
+	report(err)
+	failOn, err := cmd.Flags().GetStringSlice("fail-on")
+	if err != nil {
+		fmt.Println("Error retreiving fail-on")
+	}
+	if _, err := internal.ParseFailPolicy(failOn); err != nil {
+		report(usageError("%s", err))
+	}
+	return problems
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+}
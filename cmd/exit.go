@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/your-username/dead-link-checker/internal"
+)
+
+// Exit codes returned by the CLI so scripts and CI jobs can act on them
+const (
+	exitOK          = 0 // nothing to report
+	exitDeadLinks   = 1 // dead links were found and a --fail-on rule triggered
+	exitUsage       = 2 // bad flags, arguments or configuration
+	exitStartFailed = 3 // the start URL itself could not be fetched
+)
+
+// exitError carries the process exit code for a failed command
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// usageError reports a problem with how the command was invoked
+func usageError(format string, args ...any) error {
+	return &exitError{code: exitUsage, err: fmt.Errorf(format, args...)}
+}
+
+// exitCode maps an error returned from a command to a process exit code.
+// Errors that did not come from our commands are cobra's own flag and
+// argument errors, so they count as usage errors.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return exitUsage
+}
+
+// failOnError returns the error for the dead links that trigger the
+// --fail-on policy, or nil when none do
+func failOnError(policy internal.FailPolicy, results []internal.LinkResult, scope *internal.Scope) error {
+	triggered := policy.Triggered(internal.DeadResults(results), scope)
+	if len(triggered) == 0 {
+		return nil
+	}
+	var rules []string
+	for _, rule := range triggered {
+		rules = append(rules, rule.Expr)
+	}
+	return &exitError{code: exitDeadLinks, err: fmt.Errorf("dead links matched --fail-on %s", strings.Join(rules, ", "))}
+}
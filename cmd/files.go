@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/your-username/dead-link-checker/internal"
+)
+
+// checkFilesCmd represents the check-files command
+var checkFilesCmd = &cobra.Command{
+	Use:   "check-files <path|glob>...",
+	Short: "Check the links in Markdown and HTML files",
+	Long: `Check every link in Markdown and HTML files, such as the READMEs and docs
+of a repository, without crawling a site.
+
+Each argument is a file, a directory searched for .md, .markdown, .html and
+.htm files (skipping hidden directories and node_modules), or a glob where
+** matches any number of directories. Quote globs so the shell leaves them
+alone.
+
+Inline links and images, reference definitions, autolinks, bare URLs and
+raw HTML are read; code blocks, code spans and comments are skipped.
+Relative links are checked against the filesystem, where a directory is a
+valid target, and links starting with / resolve against --root. Fragments
+must match a heading, using GitHub's slugs or an explicit {#id}, or an id
+in the target file. Dead links are reported by file and line.
+
+Credentials are only sent to hosts named with a host= prefix or --auth-host.
+For --fail-on, internal links are the local files.`,
+	Example: `  # Check every Markdown file in the repository
+  dead-link-checker check-files .
+
+  # Check the docs only, failing only on broken links between files
+  dead-link-checker check-files "docs/**/*.md" README.md --fail-on internal`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files, err := internal.FindFiles(args)
+		if err != nil {
+			return usageError("%s", err)
+		}
+		root, err := cmd.Flags().GetString("root")
+		if err != nil {
+			fmt.Println("Error retreiving root")
+		}
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			return usageError("--root %q must be a directory", root)
+		}
+		kinds, err := readKinds(cmd)
+		if err != nil {
+			return err
+		}
+		crawl, err := internal.CollectFileLinks(files, internal.FileOptions{
+			Root:       root,
+			Kinds:      kinds,
+			Normalizer: internal.NewNormalizer(internal.DefaultNormalizeOptions()),
+		})
+		if err != nil {
+			return usageError("%s", err)
+		}
+
+		// No start host, so credentials must name theirs
+		client, redactor, err := newClient(cmd, "")
+		if err != nil {
+			return err
+		}
+		limiter, err := newLimiter(cmd)
+		if err != nil {
+			return err
+		}
+		checkOpts, err := readCheckOptions(cmd, client, limiter)
+		if err != nil {
+			return err
+		}
+		checkOpts.Local = internal.NewFileTree()
+		format, policy, err := readOutputFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Everything past here is a check failure, not a usage mistake
+		cmd.SilenceUsage = true
+
+		if format == "text" {
+			fmt.Printf("Checking %d files\n", len(files))
+		}
+		results := checkAndReport(os.Stdout, format, strings.Join(args, " "), crawl, checkOpts, redactor)
+
+		scope, _ := internal.NewScope("file:///", internal.ScopeOptions{})
+		return failOnError(policy, results, scope)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkFilesCmd)
+	addLinkFlags(checkFilesCmd.Flags())
+	checkFilesCmd.Flags().String("root", ".", "Directory that links starting with / resolve against")
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/your-username/dead-link-checker/internal"
+)
+
+// readIgnoreFlags loads the rules from --ignore-file followed by each
+// --ignore rule
+func readIgnoreFlags(cmd *cobra.Command) (internal.IgnoreList, error) {
+	var list internal.IgnoreList
+
+	ignoreFile, err := cmd.Flags().GetString("ignore-file")
+	if err != nil {
+		fmt.Println("Error retreiving ignore-file")
+	}
+	if ignoreFile != "" {
+		file, err := os.Open(ignoreFile)
+		if err != nil {
+			return nil, usageError("%s", err)
+		}
+		defer file.Close()
+
+		list, err = internal.ReadIgnoreFile(file)
+		if err != nil {
+			return nil, usageError("%s: %s", ignoreFile, err)
+		}
+	}
+
+	ignore, err := cmd.Flags().GetStringArray("ignore")
+	if err != nil {
+		fmt.Println("Error retreiving ignore")
+	}
+	rules, err := internal.ParseIgnoreList(ignore)
+	if err != nil {
+		return nil, usageError("%s", err)
+	}
+	return append(list, rules...), nil
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	neturl "net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/your-username/dead-link-checker/internal"
+)
+
+// localDir returns the directory to read when target names a local site
+// build, either a file:// URL or the path of an existing directory
+func localDir(target string) (string, bool, error) {
+	u, err := neturl.Parse(target)
+	if err == nil && u.Scheme == "file" {
+		if u.Host != "" && u.Host != "localhost" {
+			return "", false, usageError("file URL %q must name a directory on this machine", target)
+		}
+		return u.Path, true, nil
+	}
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return "", false, nil
+	}
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		return target, true, nil
+	}
+	return "", false, nil
+}
+
+// readLocalSite opens the build in dir, served at --base-url
+func readLocalSite(cmd *cobra.Command, dir string) (*internal.LocalSite, error) {
+	baseURL, err := cmd.Flags().GetString("base-url")
+	if err != nil {
+		fmt.Println("Error retreiving base-url")
+	}
+	site, err := internal.NewLocalSite(dir, baseURL)
+	if err != nil {
+		return nil, usageError("%s", err)
+	}
+	return site, nil
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/your-username/dead-link-checker/internal"
+	"io"
+	"os"
+	"strings"
+)
+
+// outputFormats lists the values accepted by --format
+var outputFormats = []string{"text", "json", "ndjson"}
+
+// printTextReport prints the human-readable report
+func printTextReport(w io.Writer, crawl *internal.CrawlResult, results []internal.LinkResult) {
+	fmt.Fprintln(w, "Collecting dead URLs:")
+	deadURLs := internal.DeadResults(results)
+	// Group by the page that needs fixing
+	for _, page := range internal.GroupByPage(deadURLs, crawl.Sources) {
+		fmt.Fprintln(w, page.Page)
+		for _, deadLink := range page.Links {
+			fmt.Fprintln(w, "  "+describeDeadLink(deadLink))
+		}
+	}
+	// Sitemap entries that need updating
+	if problems := internal.SitemapProblems(results, crawl.SitemapEntries); len(problems) > 0 {
+		fmt.Fprintln(w, "Sitemap entries that are dead or redirect:")
+		for _, problem := range problems {
+			if problem.Dead {
+				fmt.Fprintln(w, "  "+describeResult(problem))
+			} else {
+				fmt.Fprintf(w, "  %s redirects to %s\n", problem.URL, problem.FinalURL)
+			}
+		}
+	}
+	// Working links that should still be edited
+	if redirected := internal.RedirectResults(results); len(redirected) > 0 {
+		fmt.Fprintln(w, "Redirects to fix:")
+		for _, result := range redirected {
+			fmt.Fprintln(w, "  "+describeRedirect(result))
+		}
+	}
+	// Alive links whose pages look like error pages
+	if suspected := internal.Soft404Results(results); len(suspected) > 0 {
+		fmt.Fprintln(w, "Suspected soft 404s:")
+		for _, result := range suspected {
+			fmt.Fprintf(w, "  %s (%.0f%%: %s)\n", result.URL, result.Soft404*100, result.Soft404Reason)
+		}
+	}
+	// Links that passed only after retrying point at unreliable hosts
+	if flaky := internal.FlakyResults(results); len(flaky) > 0 {
+		fmt.Fprintln(w, "Alive only after retrying:")
+		for _, result := range flaky {
+			fmt.Fprintf(w, "  %s (%d attempts)\n", result.URL, result.Attempts)
+		}
+	}
+	// Links matching an ignore rule, with the failure that was silenced
+	if ignored := internal.IgnoredResults(results); len(ignored) > 0 {
+		fmt.Fprintln(w, "Ignored:")
+		for _, result := range ignored {
+			if result.Category == "" {
+				fmt.Fprintln(w, "  "+result.URL)
+			} else {
+				fmt.Fprintln(w, "  "+describeResult(result))
+			}
+		}
+	}
+	for _, sitemapErr := range crawl.SitemapErrors {
+		fmt.Fprintln(w, "Could not read sitemap "+sitemapErr)
+	}
+	// Links we were not allowed to request
+	if len(crawl.RobotsBlocked) > 0 {
+		fmt.Fprintln(w, "Skipped by robots.txt:")
+		for _, blocked := range crawl.RobotsBlocked {
+			fmt.Fprintln(w, "  "+blocked)
+		}
+	}
+	// Results taken from earlier runs may be out of date
+	if cached := internal.CachedResults(results); len(cached) > 0 {
+		fmt.Fprintf(w, "Reused %d cached results (run without --cache to check every link again)\n", len(cached))
+	}
+}
+
+// describeResult formats a dead link with the reason it failed
+func describeResult(result internal.LinkResult) string {
+	if result.Category == internal.CategoryHTTP {
+		return fmt.Sprintf("%s (%d %s)", result.URL, result.StatusCode, result.Error)
+	}
+	return fmt.Sprintf("%s (%s: %s)", result.URL, result.Category, result.Error)
+}
+
+// describeRedirect formats a redirect chain with what is wrong with it
+func describeRedirect(result internal.LinkResult) string {
+	chain := result.URL
+	for i, hop := range result.Redirects {
+		next := result.FinalURL
+		if i+1 < len(result.Redirects) {
+			next = result.Redirects[i+1].URL
+		}
+		chain += fmt.Sprintf(" -%d-> %s", hop.StatusCode, next)
+	}
+	var notes []string
+	for _, issue := range result.RedirectIssues {
+		switch issue {
+		case internal.RedirectPermanent:
+			notes = append(notes, "moved permanently, update to "+result.UpdateTo())
+		case internal.RedirectLongChain:
+			notes = append(notes, fmt.Sprintf("%d redirects", len(result.Redirects)))
+		case internal.RedirectHomepage:
+			notes = append(notes, "ends at the homepage, the page may have been removed")
+		}
+	}
+	return chain + " (" + strings.Join(notes, "; ") + ")"
+}
+
+// describeDeadLink formats a dead link with where it appears on its page
+func describeDeadLink(deadLink internal.DeadLink) string {
+	source := deadLink.Source
+	if source.Element == "" {
+		return describeResult(deadLink.Result)
+	}
+	// Show the link as written when it reads differently from the checked url
+	attribute := source.Attribute
+	if source.Original != "" && source.Original != deadLink.Result.URL {
+		attribute = fmt.Sprintf("%s=%q", source.Attribute, source.Original)
+	}
+	return fmt.Sprintf("line %d:%d <%s %s> %q -> %s",
+		source.Line, source.Column, source.Element, attribute, source.Text, describeResult(deadLink.Result))
+}
+
+// checkAndReport checks the links collected in crawl and writes the report
+// in format. Links are requested as the crawl first found them written,
+// fragments are validated against the pages the crawl read, and
+// ndjson streams each result as soon as it is checked. Everything
+// reported goes through redactor first. The results are saved to the
+// cache, if there is one, for the next run.
+func checkAndReport(out io.Writer, format, target string, crawl *internal.CrawlResult, checkOpts internal.CheckOptions, redactor *internal.Redactor) []internal.LinkResult {
+	checkOpts.Anchors = crawl.Anchors
+	checkOpts.Spellings = crawl.Spellings
+	// Links are requested as found; only the report is redacted
+	reported := redactor.Crawl(crawl)
+
+	var stream *internal.NDJSONWriter
+	if format == "ndjson" {
+		stream = internal.NewNDJSONWriter(out)
+		for _, blocked := range reported.RobotsBlocked {
+			stream.WriteSkipped(blocked, "robots")
+		}
+		inSitemap := reported.SitemapSet()
+		checkOpts.OnResult = func(result internal.LinkResult) {
+			result = redactor.Result(result)
+			stream.WriteLink(internal.NewReportLink(result, reported.Sources[result.URL], inSitemap[result.URL]))
+		}
+	}
+
+	results := internal.CheckLinks(&crawl.Links, checkOpts)
+	saveCache(checkOpts.Cache)
+
+	switch format {
+	case "json":
+		if err := internal.WriteJSON(out, internal.BuildReport(target, reported, redactor.Results(results))); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing report:", err)
+		}
+	case "ndjson":
+		stream.WriteSummary(internal.Summarize(reported, results))
+	default:
+		printTextReport(out, reported, redactor.Results(results))
+	}
+	return results
+}
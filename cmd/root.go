@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -28,10 +29,12 @@ Perfect for web developers, SEO audits, and site maintenance.`,
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, "Error:", err)
 	}
+	os.Exit(exitCode(err))
 }
 
 func init() {
-	
+	// Execute prints errors itself so it can pick the exit code
+	rootCmd.SilenceErrors = true
 }
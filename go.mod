@@ -2,10 +2,10 @@ module github.com/your-username/dead-link-checker
 
 go 1.24.4
 
-require golang.org/x/net v0.41.0
-
 require (
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/cobra v1.9.1 // indirect
-	github.com/spf13/pflag v1.0.6 // indirect
+	github.com/spf13/cobra v1.9.1
+	github.com/spf13/pflag v1.0.6
+	golang.org/x/net v0.41.0
 )
+
+require github.com/inconshreveable/mousetrap v1.1.0 // indirect
@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"fmt"
+	neturl "net/url"
+	"strings"
+)
+
+// PageAnchors holds the fragment targets of each fetched page, keyed by the
+// page url without its fragment
+type PageAnchors map[string]map[string]bool
+
+// pageKey strips the fragment from a url so every spelling of a page's
+// anchors shares one entry
+func pageKey(rawURL string) string {
+	page, _, _ := strings.Cut(rawURL, "#")
+	return page
+}
+
+// add records the anchors of a fetched page
+func (a PageAnchors) add(pageURL string, anchors map[string]bool) {
+	if anchors == nil {
+		anchors = make(map[string]bool)
+	}
+	a[pageKey(pageURL)] = anchors
+}
+
+// MissingFragment returns the fragment of rawURL when its page was fetched
+// and has no matching anchor. Urls without a fragment, pages that were not
+// fetched, and fragments every browser understands all pass.
+func (a PageAnchors) MissingFragment(rawURL string) (string, bool) {
+	page, fragment, found := strings.Cut(rawURL, "#")
+	if !found || fragment == "" {
+		return "", false
+	}
+	anchors, fetched := a[page]
+	if !fetched {
+		return "", false
+	}
+
+	// "#top" scrolls to the top and text fragments match page content
+	if strings.EqualFold(fragment, "top") || strings.HasPrefix(fragment, ":~:") {
+		return "", false
+	}
+	// Client-side routers such as "#/settings" or "#!/page" name a view,
+	// never an element id
+	if strings.HasPrefix(fragment, "/") || strings.HasPrefix(fragment, "!") {
+		return "", false
+	}
+	if anchors[fragment] {
+		return "", false
+	}
+	// Browsers also try the percent-decoded fragment
+	if decoded, err := neturl.PathUnescape(fragment); err == nil && anchors[decoded] {
+		return "", false
+	}
+	return fragment, true
+}
+
+// checkAnchor marks an otherwise alive result dead when its fragment does
+// not exist on the target page
+func (a PageAnchors) checkAnchor(result *LinkResult) {
+	if result.Dead {
+		return
+	}
+	if fragment, missing := a.MissingFragment(result.URL); missing {
+		result.Dead = true
+		result.Category = CategoryAnchor
+		result.Error = fmt.Sprintf("#%s not found on the page", fragment)
+	}
+}
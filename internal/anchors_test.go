@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPageAnchors_MissingFragment(t *testing.T) {
+	anchors := make(PageAnchors)
+	anchors.add("https://example.com/docs#intro", map[string]bool{"install": true, "café": true})
+
+	tests := []struct {
+		url     string
+		missing bool
+	}{
+		{"https://example.com/docs", false},
+		{"https://example.com/docs#", false},
+		{"https://example.com/docs#install", false},
+		{"https://example.com/docs#caf%C3%A9", false},
+		{"https://example.com/docs#top", false},
+		{"https://example.com/docs#:~:text=hello", false},
+		{"https://example.com/docs#/settings", false},
+		{"https://example.com/docs#!/page", false},
+		{"https://example.com/docs#removed", true},
+		{"https://example.com/docs#Install", true},   // ids are case sensitive
+		{"https://example.com/other#removed", false}, // page never fetched
+	}
+
+	for _, tt := range tests {
+		fragment, missing := anchors.MissingFragment(tt.url)
+		if missing != tt.missing {
+			t.Errorf("MissingFragment(%q) = %q, %v, expected missing %v", tt.url, fragment, missing, tt.missing)
+		}
+	}
+
+	// A nil set validates nothing
+	var none PageAnchors
+	if _, missing := none.MissingFragment("https://example.com/docs#removed"); missing {
+		t.Errorf("expected a nil set to skip validation")
+	}
+}
+
+func TestCheckLinks_Anchors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><body><h1 id="top-heading">Home</h1>
+<a href="#top-heading">in page</a>
+<a href="#gone">in page, renamed</a>
+<a href="/guide#setup">guide</a>
+<a href="/guide#old-setup">guide, renamed</a>
+<a href="/missing#setup">missing page</a>
+</body></html>`)
+		case "/guide":
+			fmt.Fprint(w, `<html><body><h2 id="setup">Setup</h2></body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	crawl := CrawlSite(server.URL+"/", CrawlOptions{MaxDepth: 1, Workers: 1, IgnoreRobots: true})
+	opts := CheckOptions{Concurrency: 2, PerHost: 2, Anchors: crawl.Anchors}
+	results := CheckLinks(&crawl.Links, opts)
+
+	expected := map[string]ErrorCategory{
+		server.URL + "/#top-heading":    CategoryNone,
+		server.URL + "/#gone":           CategoryAnchor,
+		server.URL + "/guide#setup":     CategoryNone,
+		server.URL + "/guide#old-setup": CategoryAnchor,
+		server.URL + "/missing#setup":   CategoryHTTP, // the page itself is broken
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %+v", len(expected), results)
+	}
+	for _, result := range results {
+		category, ok := expected[result.URL]
+		if !ok {
+			t.Errorf("unexpected link %s", result.URL)
+			continue
+		}
+		if result.Category != category || result.Dead != (category != CategoryNone) {
+			t.Errorf("%s: expected category %q, got %+v", result.URL, category, result)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	neturl "net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Credential authenticates requests to a single host
+type Credential struct {
+	Host     string // hostname, with an optional port, the credential is sent to
+	Username string // basic auth, used when Token and Header are empty
+	Password string
+	Token    string // bearer token
+	// A sensitive --header sent as it was given, such as X-Api-Key
+	Header string
+	Value  string
+}
+
+// name returns the request header the credential is sent in
+func (c Credential) name() string {
+	if c.Header != "" {
+		return http.CanonicalHeaderKey(c.Header)
+	}
+	return "Authorization"
+}
+
+// header returns the header value for the credential
+func (c Credential) header() string {
+	if c.Header != "" {
+		return c.Value
+	}
+	if c.Token != "" {
+		return "Bearer " + c.Token
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password))
+}
+
+// matches reports whether the credential belongs to the host of u
+func (c Credential) matches(u *neturl.URL) bool {
+	host := strings.ToLower(c.Host)
+	return host == strings.ToLower(u.Host) || host == strings.ToLower(u.Hostname())
+}
+
+// scopePrefix matches a "host=" or "host:port=" prefix. The host must
+// contain a dot or be localhost, so "user:1234=" is not taken for one.
+var scopePrefix = regexp.MustCompile(`^((?:[A-Za-z0-9-]+\.)+[A-Za-z0-9-]+|localhost|\[[0-9A-Fa-f:]+\])(:[0-9]+)?=`)
+
+// splitScope separates an optional host prefix from a credential value
+func splitScope(value string, defaultHosts []string) ([]string, string) {
+	if prefix := scopePrefix.FindString(value); prefix != "" {
+		return []string{strings.TrimSuffix(prefix, "=")}, value[len(prefix):]
+	}
+	return defaultHosts, value
+}
+
+// ParseBasicAuth reads a "[host=]user:password" value. Without a host the
+// credential is scoped to each of defaultHosts.
+func ParseBasicAuth(value string, defaultHosts []string) ([]Credential, error) {
+	hosts, userinfo := splitScope(value, defaultHosts)
+	username, password, found := strings.Cut(userinfo, ":")
+	if !found || username == "" {
+		return nil, fmt.Errorf("invalid basic auth, expected \"[host=]user:password\"")
+	}
+	var credentials []Credential
+	for _, host := range hosts {
+		credentials = append(credentials, Credential{Host: host, Username: username, Password: password})
+	}
+	return credentials, nil
+}
+
+// ParseBearerToken reads a "[host=]token" value. Without a host the token
+// is scoped to each of defaultHosts.
+func ParseBearerToken(value string, defaultHosts []string) ([]Credential, error) {
+	hosts, token := splitScope(value, defaultHosts)
+	if token == "" {
+		return nil, fmt.Errorf("invalid bearer token, expected \"[host=]token\"")
+	}
+	var credentials []Credential
+	for _, host := range hosts {
+		credentials = append(credentials, Credential{Host: host, Token: token})
+	}
+	return credentials, nil
+}
+
+// ScopeHeaders moves the sensitive headers, such as Authorization or
+// X-Api-Key, out of headers sent with every request and into credentials
+// for each of hosts, so they never reach external sites. Without hosts
+// the sensitive headers are dropped, which callers should report.
+func ScopeHeaders(headers http.Header, hosts []string) (http.Header, []Credential) {
+	remaining := make(http.Header)
+	var credentials []Credential
+	for name, values := range headers {
+		if !sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			remaining[name] = values
+			continue
+		}
+		for _, host := range hosts {
+			for _, value := range values {
+				credentials = append(credentials, Credential{Host: host, Header: name, Value: value})
+			}
+		}
+	}
+	return remaining, credentials
+}
+
+// authTransport adds credentials to each request, including every hop of
+// a redirect, and only for the host they were given for
+type authTransport struct {
+	base        http.RoundTripper
+	credentials []Credential
+	names       []string // headers the credentials are sent in
+	// Header values we generate, so they can be stripped when a redirect
+	// carries them to a host they do not belong to
+	ours map[string]bool
+}
+
+func newAuthTransport(base http.RoundTripper, credentials []Credential) *authTransport {
+	t := &authTransport{base: base, credentials: credentials, ours: make(map[string]bool)}
+	seen := make(map[string]bool)
+	for _, credential := range credentials {
+		name := credential.name()
+		if !seen[name] {
+			seen[name] = true
+			t.names = append(t.names, name)
+		}
+		t.ours[name+": "+credential.header()] = true
+	}
+	return t
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cloned := false
+	for _, name := range t.names {
+		header := ""
+		for _, credential := range t.credentials {
+			if credential.name() == name && credential.matches(req.URL) {
+				header = credential.header()
+				break
+			}
+		}
+
+		current := req.Header.Get(name)
+		if (header != "" && current == "") || (header == "" && t.ours[name+": "+current]) {
+			if !cloned {
+				req = req.Clone(req.Context())
+				cloned = true
+			}
+			if header != "" {
+				req.Header.Set(name, header)
+			} else {
+				req.Header.Del(name)
+			}
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// LoadCookieJar reads cookies in the Netscape cookies.txt format used by
+// curl and browser export extensions into a jar. Each cookie is only sent
+// to the domain it was issued for, and cookies set by servers during the
+// run are kept as well. Expired cookies are skipped. The loaded cookies
+// are returned too so their values can be redacted.
+func LoadCookieJar(r io.Reader) (http.CookieJar, []*http.Cookie, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cookies []*http.Cookie
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	now := time.Now()
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+			httpOnly = true
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// domain, include subdomains, path, secure, expiry, name, value
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, nil, fmt.Errorf("cookie file line %d: expected 7 tab separated fields, got %d", lineNumber, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cookie file line %d: invalid expiry %q", lineNumber, fields[4])
+		}
+
+		domain := strings.TrimPrefix(fields[0], ".")
+		cookie := &http.Cookie{
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		// Host-only cookies are the ones without a Domain attribute
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = domain
+		}
+		// 0 marks a session cookie
+		if expires != 0 {
+			cookie.Expires = time.Unix(expires, 0)
+			if cookie.Expires.Before(now) {
+				continue
+			}
+		}
+		if cookie.Path == "" {
+			cookie.Path = "/"
+		}
+
+		scheme := "http"
+		if cookie.Secure {
+			scheme = "https"
+		}
+		jar.SetCookies(&neturl.URL{Scheme: scheme, Host: domain, Path: cookie.Path}, []*http.Cookie{cookie})
+		cookies = append(cookies, cookie)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("reading cookie file: %w", err)
+	}
+	return jar, cookies, nil
+}
+
+// Redactor hides secrets such as passwords, tokens and cookie values from
+// what is reported. Only the places request data can show up are touched:
+// the password in any url, and secrets in error messages. Report text such
+// as JSON keys, statuses and link text is never rewritten. A nil Redactor
+// only hides passwords in urls.
+type Redactor struct {
+	secrets []string
+}
+
+// redactedSecret replaces each secret in output
+const redactedSecret = "[REDACTED]"
+
+// urlPassword matches the password part of userinfo in a url
+var urlPassword = regexp.MustCompile(`(://[^/?#@:\s]*:)[^/?#@\s]+@`)
+
+// NewRedactor hides every non-empty secret
+func NewRedactor(secrets ...string) *Redactor {
+	r := &Redactor{}
+	for _, secret := range secrets {
+		if secret != "" {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	// Longest first, so a secret containing another is hidden whole
+	sort.Slice(r.secrets, func(i, j int) bool {
+		return len(r.secrets[i]) > len(r.secrets[j])
+	})
+	return r
+}
+
+// URL returns rawURL with the password in its userinfo hidden
+func (r *Redactor) URL(rawURL string) string {
+	return urlPassword.ReplaceAllString(rawURL, "${1}xxxxx@")
+}
+
+// String returns s, such as an error message, with url passwords hidden
+// and every secret that appears as a whole token replaced. A secret
+// inside a longer word or value is part of something else and is left
+// alone, so every kind of secret follows the same rule whatever its
+// length.
+func (r *Redactor) String(s string) string {
+	s = r.URL(s)
+	if r == nil {
+		return s
+	}
+	for _, secret := range r.secrets {
+		s = replaceToken(s, secret)
+	}
+	return s
+}
+
+// replaceToken replaces each occurrence of secret in s that is not
+// directly preceded or followed by a character that could continue it
+func replaceToken(s, secret string) string {
+	var b strings.Builder
+	last := 0
+	for start := 0; start < len(s); {
+		i := strings.Index(s[start:], secret)
+		if i < 0 {
+			break
+		}
+		i += start
+		end := i + len(secret)
+		if (i == 0 || !tokenByte(s[i-1])) && (end == len(s) || !tokenByte(s[end])) {
+			b.WriteString(s[last:i])
+			b.WriteString(redactedSecret)
+			last, start = end, end
+		} else {
+			start = i + 1
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// tokenByte reports whether c can be part of a token: a letter, a digit
+// or one of the characters urls leave unescaped in a word
+func tokenByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '_' || c == '.' || c == '~'
+}
+
+// Result returns result with url passwords hidden in its urls and secrets
+// hidden in its error
+func (r *Redactor) Result(result LinkResult) LinkResult {
+	result.URL = r.URL(result.URL)
+	result.FinalURL = r.URL(result.FinalURL)
+	result.Error = r.String(result.Error)
+	if len(result.Redirects) > 0 {
+		redirects := make([]RedirectHop, len(result.Redirects))
+		for i, hop := range result.Redirects {
+			hop.URL = r.URL(hop.URL)
+			redirects[i] = hop
+		}
+		result.Redirects = redirects
+	}
+	return result
+}
+
+// Results returns a redacted copy of every result
+func (r *Redactor) Results(results []LinkResult) []LinkResult {
+	redacted := make([]LinkResult, len(results))
+	for i, result := range results {
+		redacted[i] = r.Result(result)
+	}
+	return redacted
+}
+
+// Crawl returns a copy of crawl to report, with url passwords hidden in
+// its urls and secrets hidden in its errors. Urls are rewritten the same
+// way as in Result, so redacted results still find their sources.
+func (r *Redactor) Crawl(crawl *CrawlResult) *CrawlResult {
+	redacted := *crawl
+	redacted.Links = r.urls(crawl.Links)
+	redacted.RobotsBlocked = r.urls(crawl.RobotsBlocked)
+	redacted.SitemapEntries = r.urls(crawl.SitemapEntries)
+	redacted.Sources = make(map[string][]LinkSource, len(crawl.Sources))
+	for url, sources := range crawl.Sources {
+		copied := make([]LinkSource, len(sources))
+		for i, source := range sources {
+			source.Page = r.URL(source.Page)
+			source.Original = r.URL(source.Original)
+			copied[i] = source
+		}
+		redacted.Sources[r.URL(url)] = copied
+	}
+	redacted.SitemapErrors = nil
+	for _, sitemapErr := range crawl.SitemapErrors {
+		redacted.SitemapErrors = append(redacted.SitemapErrors, r.String(sitemapErr))
+	}
+	redacted.StartError = r.String(crawl.StartError)
+	return &redacted
+}
+
+// urls returns a copy of urls with their passwords hidden
+func (r *Redactor) urls(urls []string) []string {
+	if urls == nil {
+		return nil
+	}
+	redacted := make([]string, len(urls))
+	for i, url := range urls {
+		redacted[i] = r.URL(url)
+	}
+	return redacted
+}
+
+// Secrets returns every secret value in the client options: credentials,
+// sensitive headers and the proxy password
+func (opts ClientOptions) Secrets() []string {
+	var secrets []string
+	for _, credential := range opts.Credentials {
+		// The encoded form of basic auth is as sensitive as the password
+		_, encoded, _ := strings.Cut(credential.header(), " ")
+		secrets = append(secrets, credential.Password, credential.Token, credential.Value, encoded)
+	}
+	for name, values := range opts.Headers {
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			secrets = append(secrets, values...)
+		}
+	}
+	if proxyURL, err := neturl.Parse(opts.Proxy); err == nil && proxyURL.User != nil {
+		if password, ok := proxyURL.User.Password(); ok {
+			secrets = append(secrets, password)
+		}
+	}
+	return secrets
+}
+
+// sensitiveHeaders are request headers whose values are always redacted
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"X-Api-Key":           true,
+	"X-Auth-Token":        true,
+}
@@ -0,0 +1,245 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cacheVersion is bumped when the cache file format changes; a file
+// written by another version is discarded rather than misread
+const cacheVersion = 2
+
+// cacheMaxAge is how long an entry is kept after it was last checked.
+// Expired alive entries stay around until then so they can be revalidated.
+const cacheMaxAge = 30 * 24 * time.Hour
+
+// CacheOptions controls how long cached results are trusted
+type CacheOptions struct {
+	AliveTTL time.Duration // alive results are reused for this long
+	DeadTTL  time.Duration // dead results are reused for this long
+}
+
+// DefaultCacheOptions returns the options used when none are given
+func DefaultCacheOptions() CacheOptions {
+	return CacheOptions{
+		AliveTTL: 24 * time.Hour,
+		DeadTTL:  time.Hour,
+	}
+}
+
+// DefaultCachePath returns the cache file in the user's cache directory
+func DefaultCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "dead-link-checker", "results.json"), nil
+}
+
+// ResultCache keeps link results between runs, keyed by normalized url.
+// A result younger than its TTL is reused without a request. An expired
+// alive result that came with an ETag or Last-Modified header is
+// revalidated with a conditional request, and a 304 reuses it again. Each
+// result remembers the request settings it was checked with, and is only
+// reused with the same ones. A nil ResultCache checks every link.
+type ResultCache struct {
+	mu      sync.Mutex
+	path    string
+	opts    CacheOptions
+	entries map[string]*cacheEntry
+}
+
+// cacheFile is the JSON form of the cache on disk
+type cacheFile struct {
+	Version int                    `json:"version"`
+	Entries map[string]*cacheEntry `json:"entries"`
+}
+
+// cacheEntry is one cached result, when it was checked and a hash of the
+// settings it was checked with
+type cacheEntry struct {
+	Result      LinkResult `json:"result"`
+	CheckedAt   time.Time  `json:"checked_at"`
+	Fingerprint string     `json:"fingerprint"`
+}
+
+// OpenCache loads the cache file at path. A missing file, or one that
+// cannot be parsed, starts an empty cache; the cache only saves requests,
+// so losing it is never an error.
+func OpenCache(path string, opts CacheOptions) (*ResultCache, error) {
+	cache := &ResultCache{path: path, opts: opts, entries: make(map[string]*cacheEntry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading cache: %w", err)
+	}
+	var file cacheFile
+	if json.Unmarshal(data, &file) == nil && file.Version == cacheVersion {
+		for url, entry := range file.Entries {
+			if entry != nil {
+				cache.entries[url] = entry
+			}
+		}
+	}
+	return cache, nil
+}
+
+// Path returns the file the cache is loaded from and saved to
+func (c *ResultCache) Path() string {
+	if c == nil {
+		return ""
+	}
+	return c.path
+}
+
+// Save writes the cache back to its file, dropping entries not checked
+// within cacheMaxAge. The file is replaced in one step, so a run that is
+// interrupted never leaves half a cache behind.
+func (c *ResultCache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	file := cacheFile{Version: cacheVersion, Entries: make(map[string]*cacheEntry)}
+	now := time.Now()
+	for url, entry := range c.entries {
+		if now.Sub(entry.CheckedAt) < cacheMaxAge {
+			file.Entries[url] = entry
+		}
+	}
+	data, err := json.Marshal(file)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".results-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// ClearCache deletes the cache file at path, if there is one
+func ClearCache(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// fresh reports whether an entry can be reused without a request
+func (c *ResultCache) fresh(entry *cacheEntry, now time.Time) bool {
+	ttl := c.opts.AliveTTL
+	if entry.Result.Dead {
+		ttl = c.opts.DeadTTL
+	}
+	return now.Sub(entry.CheckedAt) < ttl
+}
+
+// check returns the result for url, reusing a cached one checked with the
+// same fingerprint when it is still fresh and otherwise calling request.
+// Validators for a conditional request are passed to request when an
+// expired alive result has them; nil means an unconditional request.
+// Transient failures are never cached, so the next run tries them again.
+func (c *ResultCache) check(url, fingerprint string, request func(validators http.Header) LinkResult) LinkResult {
+	if c == nil {
+		return request(nil)
+	}
+	c.mu.Lock()
+	entry := c.entries[url]
+	c.mu.Unlock()
+	if entry != nil && entry.Fingerprint != fingerprint {
+		// Checked another way, so it says nothing about this run
+		entry = nil
+	}
+
+	var validators http.Header
+	if entry != nil {
+		if c.fresh(entry, time.Now()) {
+			result := entry.Result
+			result.Cached = true
+			result.Latency = 0
+			result.Attempts = 0
+			return result
+		}
+		if !entry.Result.Dead && (entry.Result.ETag != "" || entry.Result.LastModified != "") {
+			validators = make(http.Header)
+			if entry.Result.ETag != "" {
+				validators.Set("If-None-Match", entry.Result.ETag)
+			}
+			if entry.Result.LastModified != "" {
+				validators.Set("If-Modified-Since", entry.Result.LastModified)
+			}
+		}
+	}
+
+	result := request(validators)
+	if validators != nil && result.StatusCode == http.StatusNotModified {
+		// Unchanged since it was cached, so the cached result stands
+		revalidated := entry.Result
+		revalidated.Latency = result.Latency
+		revalidated.Method = result.Method
+		revalidated.Attempts = result.Attempts
+		c.store(url, fingerprint, revalidated)
+		revalidated.Cached = true
+		return revalidated
+	}
+	if !retryable(result) {
+		c.store(url, fingerprint, result)
+	}
+	return result
+}
+
+// store records a freshly checked result
+func (c *ResultCache) store(url, fingerprint string, result LinkResult) {
+	result.URL = url
+	result.Cached = false
+	c.mu.Lock()
+	c.entries[url] = &cacheEntry{Result: result, CheckedAt: time.Now(), Fingerprint: fingerprint}
+	c.mu.Unlock()
+}
+
+// cacheFingerprint hashes the settings that change what checking a link
+// returns: the request method and the client's headers and limits
+func cacheFingerprint(method string, client *Client) string {
+	sum := sha256.Sum256([]byte("method " + method + "\n" + client.fingerprint()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// CachedResults filters results down to the ones reused from the cache
+func CachedResults(results []LinkResult) []LinkResult {
+	var cached []LinkResult
+	for _, result := range results {
+		if result.Cached {
+			cached = append(cached, result)
+		}
+	}
+	return cached
+}
@@ -0,0 +1,189 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResultCache(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	conditional := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		if r.Header.Get("If-None-Match") != "" {
+			conditional[r.URL.Path]++
+		}
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/etag":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+		case "/dead":
+			http.NotFound(w, r)
+		case "/busy":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cache", "results.json")
+	urls := []string{server.URL + "/etag", server.URL + "/dead", server.URL + "/busy"}
+	run := func(cache *ResultCache) map[string]LinkResult {
+		opts := DefaultCheckOptions()
+		opts.Retry.MaxRetries = 0
+		opts.Cache = cache
+		byPath := make(map[string]LinkResult)
+		for _, result := range CheckLinks(&urls, opts) {
+			byPath[result.URL[len(server.URL):]] = result
+		}
+		return byPath
+	}
+
+	cache, err := OpenCache(path, DefaultCacheOptions())
+	if err != nil {
+		t.Fatalf("OpenCache() error = %v", err)
+	}
+	if results := run(cache); len(CachedResults([]LinkResult{results["/etag"], results["/dead"]})) != 0 {
+		t.Errorf("expected nothing cached on the first run, got %+v", results)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// A fresh run reuses alive and dead results but retries transient failures
+	cache, err = OpenCache(path, DefaultCacheOptions())
+	if err != nil {
+		t.Fatalf("OpenCache() error = %v", err)
+	}
+	results := run(cache)
+	if !results["/etag"].Cached || !results["/dead"].Cached || !results["/dead"].Dead || results["/busy"].Cached {
+		t.Errorf("unexpected cached results %+v", results)
+	}
+	if requests["/etag"] != 1 || requests["/dead"] != 1 || requests["/busy"] != 2 {
+		t.Errorf("unexpected requests %v", requests)
+	}
+
+	// Expired results are revalidated when they can be, and checked otherwise
+	for _, entry := range cache.entries {
+		entry.CheckedAt = time.Now().Add(-48 * time.Hour)
+	}
+	results = run(cache)
+	if etag := results["/etag"]; !etag.Cached || etag.Dead || etag.Attempts != 1 {
+		t.Errorf("expected a 304 to reuse the cached result, got %+v", etag)
+	}
+	if conditional["/etag"] != 1 || conditional["/dead"] != 0 || requests["/dead"] != 2 {
+		t.Errorf("expected one conditional request, got %v of %v", conditional, requests)
+	}
+	if fresh := cache.entries[server.URL+"/etag"]; time.Since(fresh.CheckedAt) > time.Minute {
+		t.Errorf("expected a revalidated entry to be fresh again, checked at %v", fresh.CheckedAt)
+	}
+
+	// Without a cache every link is requested
+	run(nil)
+	if requests["/etag"] != 3 {
+		t.Errorf("expected a nil cache to check every link, got %v", requests)
+	}
+}
+
+func TestResultCache_Skipped(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+	}))
+	defer server.Close()
+	urls := []string{server.URL + "/page"}
+
+	cache, err := OpenCache(filepath.Join(t.TempDir(), "results.json"), DefaultCacheOptions())
+	if err != nil {
+		t.Fatalf("OpenCache() error = %v", err)
+	}
+	run := func(opts CheckOptions) LinkResult {
+		opts.Retry.MaxRetries = 0
+		opts.Cache = cache
+		return CheckLinks(&urls, opts)[0]
+	}
+	run(DefaultCheckOptions())
+
+	t.Run("internal links", func(t *testing.T) {
+		before := requests
+		opts := DefaultCheckOptions()
+		opts.Scope, _ = NewScope(server.URL+"/", ScopeOptions{})
+		if result := run(opts); result.Cached || requests != before+1 {
+			t.Errorf("expected a link on the site to be requested, got %+v after %d requests", result, requests-before)
+		}
+	})
+
+	t.Run("other settings", func(t *testing.T) {
+		before := requests
+		opts := DefaultCheckOptions()
+		opts.Method = MethodGet
+		if result := run(opts); result.Cached || requests != before+1 {
+			t.Errorf("expected a result checked with HEAD not to be reused for GET, got %+v", result)
+		}
+		if result := run(opts); !result.Cached || requests != before+1 {
+			t.Errorf("expected the GET result to be reused, got %+v", result)
+		}
+	})
+
+	t.Run("credentials", func(t *testing.T) {
+		clientOpts := DefaultClientOptions()
+		clientOpts.Credentials, _ = ParseBearerToken("tok-abc", []string{"example.com"})
+		client, err := NewClient(clientOpts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := requests
+		opts := DefaultCheckOptions()
+		opts.Client = client
+		run(opts)
+		if result := run(opts); result.Cached || requests != before+2 {
+			t.Errorf("expected nothing cached with credentials, got %+v after %d requests", result, requests-before)
+		}
+	})
+}
+
+func TestOpenCache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "results.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := OpenCache(path, DefaultCacheOptions())
+	if err != nil || len(cache.entries) != 0 {
+		t.Errorf("expected an unreadable cache to start empty, got %v, %v", cache, err)
+	}
+
+	cache.store("https://example.com/", "", LinkResult{StatusCode: 200})
+	cache.store("https://example.com/old", "", LinkResult{StatusCode: 200})
+	cache.entries["https://example.com/old"].CheckedAt = time.Now().Add(-cacheMaxAge)
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	cache, err = OpenCache(path, DefaultCacheOptions())
+	if err != nil || len(cache.entries) != 1 || cache.entries["https://example.com/"] == nil {
+		t.Errorf("expected only the recent entry to be saved, got %v, %v", cache.entries, err)
+	}
+
+	if err := ClearCache(path); err != nil {
+		t.Fatalf("ClearCache() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the cache file to be removed")
+	}
+	if err := ClearCache(path); err != nil {
+		t.Errorf("expected clearing a missing cache to succeed, got %v", err)
+	}
+}
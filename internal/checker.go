@@ -1,70 +1,476 @@
 package internal
 
 import (
-	"fmt"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
 	"net/http"
+	neturl "net/url"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
-// GetDeadLinks checks for dead urls
-func GetDeadLinks(urls *[]string) []string {
-	// Holds dead links
-	var deadLinks []string
-	// Protext deadLinks from race conditions
-	var mu sync.Mutex
-	// Waits for all goroutines to finihs
+// ErrorCategory describes why a link was judged dead
+type ErrorCategory string
+
+const (
+	CategoryNone     ErrorCategory = ""
+	CategoryInvalid  ErrorCategory = "invalid"
+	CategoryDNS      ErrorCategory = "dns"
+	CategoryTimeout  ErrorCategory = "timeout"
+	CategoryTLS      ErrorCategory = "tls"
+	CategoryRefused  ErrorCategory = "refused"
+	CategoryNetwork  ErrorCategory = "network"
+	CategoryRedirect ErrorCategory = "redirect"
+	CategoryAnchor   ErrorCategory = "anchor"
+	CategoryFile     ErrorCategory = "file" // no file on disk serves a local link
+	CategoryHTTP     ErrorCategory = "http"
+)
+
+// LinkResult holds the outcome of checking a single link
+type LinkResult struct {
+	URL        string
+	StatusCode int
+	Dead       bool
+	Category   ErrorCategory
+	Error      string
+	FinalURL   string
+	Redirected bool
+	Latency    time.Duration
+	Method     string        // HTTP method that produced the result
+	Attempts   int           // number of times the link was requested
+	Ignored    bool          // matched an ignore rule; Dead is false
+	Redirects  []RedirectHop // every redirect followed, in order
+	// Problems with the redirects worth fixing even though the link works:
+	// RedirectPermanent, RedirectLongChain or RedirectHomepage
+	RedirectIssues []string
+	// Confidence from 0 to 1 that an alive page is really an error page,
+	// 0 when it is not suspected, and what gave it away
+	Soft404       float64
+	Soft404Reason string
+	// Validators from the response, sent when the result is revalidated
+	ETag         string
+	LastModified string
+	Cached       bool // reused from the result cache, as stored or after a 304
+}
+
+// Flaky reports whether the link only came back alive after a retry
+func (r LinkResult) Flaky() bool {
+	return !r.Dead && !r.Ignored && r.Attempts > 1
+}
+
+// Strategies for choosing the request method
+const (
+	MethodAuto = "auto" // HEAD first, falling back to GET when HEAD is not supported
+	MethodHead = "head" // HEAD only
+	MethodGet  = "get"  // GET only, without reading the body
+)
+
+// CheckMethods lists every accepted method strategy
+var CheckMethods = []string{MethodAuto, MethodHead, MethodGet}
+
+// headFallbackStatuses are responses to HEAD that often mean the server
+// does not handle HEAD properly rather than that the link is broken
+var headFallbackStatuses = map[int]bool{
+	http.StatusBadRequest:       true,
+	http.StatusForbidden:        true,
+	http.StatusMethodNotAllowed: true,
+	http.StatusNotAcceptable:    true,
+	http.StatusNotImplemented:   true,
+}
+
+// CheckOptions controls how links are checked
+type CheckOptions struct {
+	Concurrency int    // maximum requests in flight overall
+	PerHost     int    // maximum requests in flight to any one host
+	Method      string // MethodAuto, MethodHead or MethodGet
+	Retry       RetryPolicy
+	Limiter     *RateLimiter      // shared with the crawler; nil for no rate limit
+	Client      *Client           // shared with the crawler; nil for the defaults
+	Anchors     PageAnchors       // anchors of crawled pages, to validate fragments; nil to skip
+	Normalizer  *Normalizer       // urls with the same canonical page are requested once; nil only ignores fragments
+	Spellings   map[string]string // url to request for a link, as first written; links not in it are requested as given
+	Ignore      IgnoreList        // links never requested, or whose failures are not reported as dead
+	LongChain   int               // redirect chains longer than this are flagged, 0 to never flag them
+	Soft404     *Soft404Detector  // looks for error pages served as 200; nil to skip
+	Local       *LocalSite        // links it serves are looked up on disk instead of requested; nil for none
+	Cache       *ResultCache      // results of external links reused from earlier runs; nil to check every link
+	Scope       *Scope            // links internal to it are never cached; nil treats every link as external
+
+	// OnResult, if set, is called with each result as soon as it is ready.
+	// Calls never overlap, so the callback need not be safe for concurrent use.
+	OnResult func(LinkResult)
+}
+
+// DefaultCheckOptions returns the options used when none are given
+func DefaultCheckOptions() CheckOptions {
+	return CheckOptions{
+		Concurrency: 20,
+		PerHost:     4,
+		Method:      MethodAuto,
+		Retry:       DefaultRetryPolicy(),
+		Normalizer:  NewNormalizer(DefaultNormalizeOptions()),
+		LongChain:   3,
+	}
+}
+
+// GetDeadLinks checks the urls and returns only the results judged dead
+func GetDeadLinks(urls *[]string) []LinkResult {
+	return DeadResults(CheckLinks(urls, DefaultCheckOptions()))
+}
+
+// DeadResults filters results down to the ones judged dead
+func DeadResults(results []LinkResult) []LinkResult {
+	var deadLinks []LinkResult
+	for _, result := range results {
+		if result.Dead {
+			deadLinks = append(deadLinks, result)
+		}
+	}
+	return deadLinks
+}
+
+// IgnoredResults filters results down to the ones matching an ignore rule
+func IgnoredResults(results []LinkResult) []LinkResult {
+	var ignored []LinkResult
+	for _, result := range results {
+		if result.Ignored {
+			ignored = append(ignored, result)
+		}
+	}
+	return ignored
+}
+
+// FlakyResults filters results down to the ones that needed retries to pass
+func FlakyResults(results []LinkResult) []LinkResult {
+	var flaky []LinkResult
+	for _, result := range results {
+		if result.Flaky() {
+			flaky = append(flaky, result)
+		}
+	}
+	return flaky
+}
+
+// CheckLinks checks every url and returns a result for each one, in input
+// order. Work is fed to a fixed pool of workers through a queue, so the
+// number of open connections stays bounded however many links there are.
+// A link is only handed to a worker once its host has a free slot, so a
+// busy host never holds up links to other hosts.
+func CheckLinks(urls *[]string, opts CheckOptions) []LinkResult {
+	// One slot per url so workers never write to the same index
+	results := make([]LinkResult, len(*urls))
+	// Waits for all workers to finish
 	var wg sync.WaitGroup
 
-	// Client with timeout
-	client := &http.Client{
-		Timeout: time.Second * 10,
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	hosts := newHostScheduler(opts.PerHost)
+	// Results fetched with credentials or cookies may differ from what
+	// anyone else sees, so they are neither reused nor cached
+	cache := opts.Cache
+	if opts.Client.authenticated() {
+		cache = nil
+	}
+	fingerprint := cacheFingerprint(opts.Method, opts.Client)
+	// Serializes OnResult calls
+	var resultMu sync.Mutex
+
+	// Spellings of the same page share one request. Each group holds the
+	// indexes into urls of one canonical page, in order of first appearance.
+	var groups [][]int
+	var groupHosts []string // host each group counts against, "" for none
+	groupOf := make(map[string]int)
+	for i, url := range *urls {
+		// Ignored links are reported without a request
+		if opts.Ignore.Skip(url) {
+			results[i] = LinkResult{URL: url, Ignored: true}
+			if opts.OnResult != nil {
+				opts.OnResult(results[i])
+			}
+			continue
+		}
+		key := opts.Normalizer.PageURL(url)
+		g, ok := groupOf[key]
+		if !ok {
+			g = len(groups)
+			groupOf[key] = g
+			groups = append(groups, nil)
+			// Local files take no connection to any host
+			host := ""
+			if !opts.Local.Contains(key) {
+				host = hostOf(key)
+			}
+			groupHosts = append(groupHosts, host)
+			hosts.add(host, g)
+		}
+		groups[g] = append(groups[g], i)
 	}
 
-	for _, url := range *urls {
-		// Tell waitgroup a goroutine is starting
+	// Queue of indexes into groups, and the host of each finished one
+	jobs := make(chan int)
+	finished := make(chan string)
+
+	for w := 0; w < opts.Concurrency; w++ {
+		// Tell waitgroup a worker is starting
 		wg.Add(1)
 
-		go func(url string) {
-			// Tell waitgroup this curren goroutine is complete
+		go func() {
+			// Tell waitgroup this worker is complete
 			defer wg.Done()
+			for g := range jobs {
+				// The canonical form only groups spellings; the page is
+				// requested as it was first written
+				first := (*urls)[groups[g][0]]
+				url := opts.Normalizer.PageURL(first)
+				written := first
+				if spelling, ok := opts.Spellings[first]; ok {
+					written = spelling
+				}
+				written = pageKey(written)
+				var result LinkResult
+				if opts.Local.Contains(url) {
+					result = opts.Local.check(url)
+				} else {
+					// The site being checked is always requested, since
+					// its own changes are what a run is looking for
+					linkCache := cache
+					if opts.Scope != nil && opts.Scope.Internal(url) {
+						linkCache = nil
+					}
+					result = linkCache.check(url, fingerprint, func(validators http.Header) LinkResult {
+						return checkWithRetry(written, validators, opts)
+					})
+					opts.Soft404.check(&result)
+				}
 
-			// Create a head request to url
-			req, err := http.NewRequest("GET", url, nil)
-			if err != nil {
-				fmt.Printf("Error creating request for URL %s: %s\n", url, err)
-				mu.Lock()
-				deadLinks = append(deadLinks, url) // Network error
-				mu.Unlock()
-				return
-			}
+				// Every spelling is reported as written, with its own fragment checked
+				for _, i := range groups[g] {
+					results[i] = result
+					results[i].URL = (*urls)[i]
+					opts.Anchors.checkAnchor(&results[i])
+					checkRedirects(&results[i], opts.LongChain)
+					opts.Ignore.suppress(&results[i])
 
-			// Add user agent header
-			req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-
-			// Execute request
-			resp, err := client.Do(req)
-			if err != nil {
-				fmt.Printf("Error getting URL %s: %s\n", url, err)
-				mu.Lock()
-				deadLinks = append(deadLinks, url)
-				mu.Unlock()
-				return
+					if opts.OnResult != nil {
+						resultMu.Lock()
+						opts.OnResult(results[i])
+						resultMu.Unlock()
+					}
+				}
+				finished <- groupHosts[g]
 			}
-			resp.Body.Close() // close response body
+		}()
+	}
+
+	for done := 0; done < len(groups); {
+		host, g, ok := hosts.next()
+		if !ok {
+			// Every queued host is at its limit until a link finishes
+			hosts.finish(<-finished)
+			done++
+			continue
+		}
+		select {
+		case jobs <- g:
+			hosts.start(host)
+		case host := <-finished:
+			hosts.finish(host)
+			done++
+		}
+	}
+	close(jobs)
+
+	// Wait for all the workers to be done then return
+	wg.Wait()
+	return results
+}
+
+// hostScheduler queues jobs per host and caps how many run against a
+// single host at once. Jobs under the host "" are never limited. It is
+// only used by the goroutine dispatching jobs, so it needs no locking.
+type hostScheduler struct {
+	limit    int
+	queues   map[string][]int // jobs waiting for each host, oldest first
+	inFlight map[string]int
+}
 
-			// After following a redirect, only treat 4xx or 5xx as dead
-			if resp.StatusCode >= 400 {
-				mu.Lock()
-				deadLinks = append(deadLinks, url) // Non 2xx code
-				mu.Unlock()
+func newHostScheduler(limit int) *hostScheduler {
+	if limit < 1 {
+		limit = 1
+	}
+	return &hostScheduler{
+		limit:    limit,
+		queues:   make(map[string][]int),
+		inFlight: make(map[string]int),
+	}
+}
+
+// add queues a job for host
+func (h *hostScheduler) add(host string, job int) {
+	h.queues[host] = append(h.queues[host], job)
+}
+
+// next returns the oldest queued job whose host has a free slot, leaving
+// it queued until start is called
+func (h *hostScheduler) next() (string, int, bool) {
+	best, bestJob, found := "", 0, false
+	for host, queue := range h.queues {
+		if host != "" && h.inFlight[host] >= h.limit {
+			continue
+		}
+		if !found || queue[0] < bestJob {
+			best, bestJob, found = host, queue[0], true
+		}
+	}
+	return best, bestJob, found
+}
+
+// start takes the job returned by next off its host's queue
+func (h *hostScheduler) start(host string) {
+	if h.queues[host] = h.queues[host][1:]; len(h.queues[host]) == 0 {
+		delete(h.queues, host)
+	}
+	h.inFlight[host]++
+}
+
+// finish frees the slot of a job that is done
+func (h *hostScheduler) finish(host string) {
+	h.inFlight[host]--
+}
+
+// hostOf returns the lowercase hostname of a url, or "" if it cannot be parsed
+func hostOf(rawURL string) string {
+	parsed, err := neturl.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// checkLink requests a single url using the given method strategy. In
+// auto mode a cheap HEAD is tried first and GET is only used when the
+// server answers HEAD in a way that suggests it does not support it.
+// The server's Retry-After delay, if any, is returned alongside.
+func checkLink(client *Client, url string, method string, validators http.Header) (LinkResult, time.Duration) {
+	if method == MethodGet {
+		return requestLink(client, http.MethodGet, url, validators)
+	}
+	result, retryAfter := requestLink(client, http.MethodHead, url, validators)
+	if method == MethodHead || !headUnsupported(result) {
+		return result, retryAfter
+	}
+	return requestLink(client, http.MethodGet, url, validators)
+}
+
+// headUnsupported reports whether a HEAD result should be retried with GET
+func headUnsupported(result LinkResult) bool {
+	// Some servers drop the connection instead of answering HEAD
+	if result.Category == CategoryNetwork {
+		return true
+	}
+	return headFallbackStatuses[result.StatusCode]
+}
+
+// requestLink sends a single request and records how the url responded.
+// The body is never read, so a GET costs little more than its headers.
+// Retry-After is only honoured on 429 and 503 responses. Validators, if
+// any, make the request conditional, so an unchanged page answers 304.
+func requestLink(client *Client, method string, url string, validators http.Header) (LinkResult, time.Duration) {
+	result := LinkResult{URL: url, Method: method}
+
+	// Create a request to url
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		result.Dead = true
+		result.Category = CategoryInvalid
+		result.Error = err.Error()
+		return result, 0
+	}
+	for name, values := range validators {
+		req.Header[name] = values
+	}
+
+	// Execute request and time it
+	start := time.Now()
+	resp, err := client.Do(req)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Dead = true
+		result.Category = categorizeError(err)
+		result.Error = err.Error()
+		// A refused redirect still returns the response that asked for it
+		if resp != nil {
+			result.Redirects = append(redirectChain(resp), RedirectHop{URL: resp.Request.URL.String(), StatusCode: resp.StatusCode})
+			if location, err := resp.Location(); err == nil {
+				result.FinalURL = location.String()
 			}
+		}
+		return result, 0
+	}
+	resp.Body.Close() // close response body without downloading it
 
-		}(url)
+	result.StatusCode = resp.StatusCode
+	result.FinalURL = resp.Request.URL.String()
+	result.Redirected = result.FinalURL != req.URL.String()
+	result.Redirects = redirectChain(resp)
+	result.ETag = resp.Header.Get("ETag")
+	result.LastModified = resp.Header.Get("Last-Modified")
 
+	// After following a redirect, only treat 4xx or 5xx as dead
+	if resp.StatusCode >= 400 {
+		result.Dead = true
+		result.Category = CategoryHTTP
+		result.Error = http.StatusText(resp.StatusCode)
 	}
 
-	// Wait for all the goroutines to be done then return
-	wg.Wait()
-	return deadLinks
+	var retryAfter time.Duration
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return result, retryAfter
+}
+
+// categorizeError maps a transport error onto an ErrorCategory
+func categorizeError(err error) ErrorCategory {
+	// Redirect loops and overly long chains
+	if errors.Is(err, ErrTooManyRedirects) || errors.Is(err, ErrRedirectLoop) {
+		return CategoryRedirect
+	}
+
+	// DNS lookup failures
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return CategoryDNS
+	}
+
+	// Timeouts from the client or the dialer
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return CategoryTimeout
+	}
+
+	// Certificate and handshake problems
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &certErr) || errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidCert) ||
+		errors.As(err, &recordErr) {
+		return CategoryTLS
+	}
+
+	// Nothing listening on the port
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return CategoryRefused
+	}
+
+	return CategoryNetwork
 }
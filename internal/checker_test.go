@@ -1,9 +1,19 @@
 package internal
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestGetDeadLinks(t *testing.T) {
@@ -61,16 +71,19 @@ func TestGetDeadLinks(t *testing.T) {
 	}
 
 	// compare result to expected result (order independent)
-	for _, resultURL := range resultURLs {
-		if !expectedDeadURLs[resultURL] {
-			t.Errorf("unexpected dead URL '%s'", resultURL)
+	for _, result := range resultURLs {
+		if !expectedDeadURLs[result.URL] {
+			t.Errorf("unexpected dead URL '%s'", result.URL)
+		}
+		if result.Category != CategoryHTTP {
+			t.Errorf("expected category %q for '%s', got %q", CategoryHTTP, result.URL, result.Category)
 		}
 	}
 
 	// check that all expected URLs are present
 	resultMap := make(map[string]bool)
-	for _, url := range resultURLs {
-		resultMap[url] = true
+	for _, result := range resultURLs {
+		resultMap[result.URL] = true
 	}
 
 	for expectedURL := range expectedDeadURLs {
@@ -79,3 +92,275 @@ func TestGetDeadLinks(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckLinks(t *testing.T) {
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer okServer.Close()
+
+	redirectServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, okServer.URL+"/landing", http.StatusFound)
+	}))
+	defer redirectServer.Close()
+
+	goneServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer goneServer.Close()
+
+	// Grab a free port and close it so nothing is listening
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedURL := closed.URL
+	closed.Close()
+
+	// Self-signed certificate the default client will not trust
+	tlsServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tlsServer.Config.ErrorLog = log.New(io.Discard, "", 0) // silence handshake errors
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	testURLs := []string{okServer.URL, redirectServer.URL, goneServer.URL, closedURL, "ht tp://bad", tlsServer.URL}
+	results := CheckLinks(&testURLs, DefaultCheckOptions())
+
+	if len(results) != len(testURLs) {
+		t.Fatalf("expected %d results, got %d", len(testURLs), len(results))
+	}
+
+	// results keep input order
+	for i, result := range results {
+		if result.URL != testURLs[i] {
+			t.Errorf("result %d: expected URL %q, got %q", i, testURLs[i], result.URL)
+		}
+	}
+
+	if results[0].Dead || results[0].StatusCode != http.StatusOK {
+		t.Errorf("expected alive 200 for ok server, got %+v", results[0])
+	}
+	if results[0].Redirected {
+		t.Errorf("expected ok server not to redirect, got %+v", results[0])
+	}
+	if results[1].Dead || !results[1].Redirected || results[1].FinalURL != okServer.URL+"/landing" {
+		t.Errorf("expected redirect to be followed to landing page, got %+v", results[1])
+	}
+	if !results[2].Dead || results[2].StatusCode != http.StatusGone || results[2].Category != CategoryHTTP {
+		t.Errorf("expected dead 410 with http category, got %+v", results[2])
+	}
+	if !results[3].Dead || results[3].Category != CategoryRefused || results[3].Error == "" {
+		t.Errorf("expected refused connection, got %+v", results[3])
+	}
+	if !results[4].Dead || results[4].Category != CategoryInvalid {
+		t.Errorf("expected invalid url, got %+v", results[4])
+	}
+	if !results[5].Dead || results[5].Category != CategoryTLS {
+		t.Errorf("expected tls failure, got %+v", results[5])
+	}
+}
+
+func TestCheckLinks_Limits(t *testing.T) {
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			seen := atomic.LoadInt32(&maxInFlight)
+			if current <= seen || atomic.CompareAndSwapInt32(&maxInFlight, seen, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}))
+	defer server.Close()
+
+	var urls []string
+	for i := 0; i < 12; i++ {
+		urls = append(urls, fmt.Sprintf("%s/page/%d", server.URL, i))
+	}
+
+	tests := []struct {
+		name     string
+		opts     CheckOptions
+		expected int32
+	}{
+		{"per host limit", CheckOptions{Concurrency: 8, PerHost: 2}, 2},
+		{"global limit", CheckOptions{Concurrency: 3, PerHost: 10}, 3},
+		{"zero values fall back to one", CheckOptions{}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&maxInFlight, 0)
+			results := CheckLinks(&urls, tt.opts)
+			if len(results) != len(urls) {
+				t.Fatalf("expected %d results, got %d", len(urls), len(results))
+			}
+			if got := atomic.LoadInt32(&maxInFlight); got > tt.expected {
+				t.Errorf("expected at most %d requests in flight, saw %d", tt.expected, got)
+			}
+			for _, result := range results {
+				if result.Dead {
+					t.Errorf("expected %s to be alive, got %+v", result.URL, result)
+				}
+			}
+		})
+	}
+
+	// A slow host must not hold up links to another host
+	t.Run("busy host", func(t *testing.T) {
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(300 * time.Millisecond)
+		}))
+		defer slow.Close()
+		fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer fast.Close()
+		// Hosts are told apart by name, not port
+		fastURL := strings.Replace(fast.URL, "127.0.0.1", "localhost", 1)
+
+		var mixed []string
+		for i := 0; i < 8; i++ {
+			mixed = append(mixed, fmt.Sprintf("%s/page/%d", slow.URL, i))
+		}
+		for i := 0; i < 4; i++ {
+			mixed = append(mixed, fmt.Sprintf("%s/page/%d", fastURL, i))
+		}
+
+		start := time.Now()
+		var fastDone time.Duration
+		opts := CheckOptions{Concurrency: 8, PerHost: 2, OnResult: func(result LinkResult) {
+			if strings.HasPrefix(result.URL, fastURL) {
+				fastDone = time.Since(start)
+			}
+		}}
+		CheckLinks(&mixed, opts)
+		if fastDone > 150*time.Millisecond {
+			t.Errorf("expected the fast host to finish while the slow one was busy, took %v", fastDone)
+		}
+	})
+}
+
+func TestCheckLinks_OnResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	urls := []string{server.URL + "/a", server.URL + "/gone", server.URL + "/b", server.URL + "/c"}
+	seen := make(map[string]bool)
+	opts := CheckOptions{Concurrency: 4, PerHost: 4, OnResult: func(result LinkResult) {
+		// Calls are serialized, so no lock is needed here
+		seen[result.URL] = result.Dead
+	}}
+
+	results := CheckLinks(&urls, opts)
+	if len(seen) != len(urls) {
+		t.Fatalf("expected a callback per url, got %v", seen)
+	}
+	for _, result := range results {
+		if seen[result.URL] != result.Dead {
+			t.Errorf("callback for %s disagreed with result %+v", result.URL, result)
+		}
+	}
+}
+
+func TestCheckLinks_Method(t *testing.T) {
+	// Records the methods each path received
+	var mu sync.Mutex
+	requests := make(map[string][]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path] = append(requests[r.URL.Path], r.Method)
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/no-head" && r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/gone":
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/large":
+			// The checker should hang up long before this is sent
+			w.Write(make([]byte, 1<<20))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		dead     bool
+		used     string
+		requests []string
+	}{
+		{"auto head works", MethodAuto, "/ok", false, http.MethodHead, []string{"HEAD"}},
+		{"auto falls back", MethodAuto, "/no-head", false, http.MethodGet, []string{"HEAD", "GET"}},
+		{"auto real 404", MethodAuto, "/gone", true, http.MethodHead, []string{"HEAD"}},
+		{"empty is auto", "", "/ok", false, http.MethodHead, []string{"HEAD"}},
+		{"forced head", MethodHead, "/no-head", true, http.MethodHead, []string{"HEAD"}},
+		{"forced get", MethodGet, "/large", false, http.MethodGet, []string{"GET"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			delete(requests, tt.path)
+			mu.Unlock()
+
+			urls := []string{server.URL + tt.path}
+			result := CheckLinks(&urls, CheckOptions{Concurrency: 1, PerHost: 1, Method: tt.method})[0]
+			if result.Dead != tt.dead || result.Method != tt.used {
+				t.Errorf("expected dead=%v method=%s, got %+v", tt.dead, tt.used, result)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if fmt.Sprint(requests[tt.path]) != fmt.Sprint(tt.requests) {
+				t.Errorf("expected requests %v, got %v", tt.requests, requests[tt.path])
+			}
+		})
+	}
+}
+
+func TestHostOf(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://Example.COM:8080/path", "example.com"},
+		{"http://127.0.0.1/", "127.0.0.1"},
+		{"ht tp://bad", ""},
+	}
+	for _, tt := range tests {
+		if got := hostOf(tt.url); got != tt.expected {
+			t.Errorf("hostOf(%q) = %q, expected %q", tt.url, got, tt.expected)
+		}
+	}
+}
+
+// timeoutError satisfies net.Error and always reports a timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestCategorizeError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected ErrorCategory
+	}{
+		{"dns", &net.DNSError{Err: "no such host", Name: "nope.invalid"}, CategoryDNS},
+		{"timeout", fmt.Errorf("get: %w", timeoutError{}), CategoryTimeout},
+		{"refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, CategoryRefused},
+		{"other", errors.New("connection reset"), CategoryNetwork},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := categorizeError(tt.err); got != tt.expected {
+				t.Errorf("categorizeError(%v) = %q, expected %q", tt.err, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrTooManyRedirects is returned when a url redirects more times than allowed
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// ErrRedirectLoop is returned when a redirect leads back to a url already
+// requested in the same chain
+var ErrRedirectLoop = errors.New("redirect loop")
+
+// ClientOptions configures the HTTP client shared by every stage: page
+// fetches, robots.txt, sitemaps and link checks
+type ClientOptions struct {
+	ConnectTimeout  time.Duration // dialing and TLS handshake, 0 for no limit
+	ResponseTimeout time.Duration // waiting for response headers once the request is sent
+	Timeout         time.Duration // whole request including reading the body
+	UserAgent       string
+	Headers         http.Header    // sent with every request
+	Proxy           string         // proxy url; empty uses HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	MaxRedirects    int            // redirects followed before giving up
+	Credentials     []Credential   // each sent only to its own host
+	CookieJar       http.CookieJar // cookies sent and collected, nil for none
+}
+
+// DefaultClientOptions returns the options used when none are given
+func DefaultClientOptions() ClientOptions {
+	return ClientOptions{
+		ConnectTimeout:  10 * time.Second,
+		ResponseTimeout: 10 * time.Second,
+		Timeout:         30 * time.Second,
+		UserAgent:       "Mozilla/5.0 (compatible; dead-link-checker)",
+		MaxRedirects:    10,
+	}
+}
+
+// Client sends requests with the configured timeouts, headers and proxy.
+// A nil Client uses the defaults.
+type Client struct {
+	http *http.Client
+	opts ClientOptions
+}
+
+// defaultClient serves nil Clients and the package level Scrape
+var defaultClient, _ = NewClient(DefaultClientOptions())
+
+// NewClient builds a client from the options. It only fails when the
+// proxy url cannot be parsed.
+func NewClient(opts ClientOptions) (*Client, error) {
+	proxy := http.ProxyFromEnvironment
+	if opts.Proxy != "" {
+		proxyURL, err := neturl.Parse(opts.Proxy)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url %q", opts.Proxy)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.DialContext = (&net.Dialer{
+		Timeout:   opts.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = opts.ConnectTimeout
+	transport.ResponseHeaderTimeout = opts.ResponseTimeout
+
+	var roundTripper http.RoundTripper = transport
+	if len(opts.Credentials) > 0 {
+		roundTripper = newAuthTransport(transport, opts.Credentials)
+	}
+
+	maxRedirects := opts.MaxRedirects
+	return &Client{
+		opts: opts,
+		http: &http.Client{
+			Transport: roundTripper,
+			Jar:       opts.CookieJar,
+			Timeout:   opts.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				for _, previous := range via {
+					if previous.URL.String() == req.URL.String() {
+						return fmt.Errorf("%w back to %s", ErrRedirectLoop, req.URL)
+					}
+				}
+				if len(via) > maxRedirects {
+					return fmt.Errorf("%w (limit %d)", ErrTooManyRedirects, maxRedirects)
+				}
+				return nil
+			},
+		},
+	}, nil
+}
+
+// Do sends a request after adding the configured user agent and headers.
+// Headers already set on the request are left alone.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if c == nil {
+		c = defaultClient
+	}
+	if c.opts.UserAgent != "" && req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", c.opts.UserAgent)
+	}
+	for name, values := range c.opts.Headers {
+		if _, ok := req.Header[name]; ok {
+			continue
+		}
+		// Go sends the Host header from req.Host, not from the map
+		if name == "Host" {
+			req.Host = values[0]
+			continue
+		}
+		req.Header[name] = values
+	}
+	return c.http.Do(req)
+}
+
+// Get sends a GET request for url
+func (c *Client) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// ParseHeaders reads "Name: value" pairs, as given to --header
+func ParseHeaders(values []string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, value := range values {
+		name, content, found := strings.Cut(value, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header %q, expected \"Name: value\"", value)
+		}
+		headers.Add(name, strings.TrimSpace(content))
+	}
+	return headers, nil
+}
+
+// fingerprint identifies the client settings that change what a request
+// returns, so a result checked with one set is not reused for another.
+// Credentials are left out; see authenticated.
+func (c *Client) fingerprint() string {
+	if c == nil {
+		c = defaultClient
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "user-agent %s\nmax-redirects %d\n", c.opts.UserAgent, c.opts.MaxRedirects)
+	names := make([]string, 0, len(c.opts.Headers))
+	for name := range c.opts.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\n", http.CanonicalHeaderKey(name), strings.Join(c.opts.Headers[name], ", "))
+	}
+	return b.String()
+}
+
+// authenticated reports whether requests may carry credentials or cookies
+func (c *Client) authenticated() bool {
+	return c != nil && (len(c.opts.Credentials) > 0 || c.opts.CookieJar != nil)
+}
@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_Headers(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+	defer server.Close()
+
+	headers, err := ParseHeaders([]string{"X-Token: secret", "Accept-Language: en", "Host: docs.example.com"})
+	if err != nil {
+		t.Fatalf("ParseHeaders() error = %v", err)
+	}
+	opts := DefaultClientOptions()
+	opts.UserAgent = "link-bot/2.0"
+	opts.Headers = headers
+	client, err := NewClient(opts)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	if _, err := client.Scrape(server.URL); err != nil {
+		t.Fatalf("Scrape() error = %v", err)
+	}
+	if got.Header.Get("User-Agent") != "link-bot/2.0" {
+		t.Errorf("expected the configured user agent, got %q", got.Header.Get("User-Agent"))
+	}
+	if got.Header.Get("X-Token") != "secret" || got.Header.Get("Accept-Language") != "en" {
+		t.Errorf("expected custom headers, got %v", got.Header)
+	}
+	if got.Host != "docs.example.com" {
+		t.Errorf("expected the Host header to be applied, got %q", got.Host)
+	}
+
+	// Headers set on the request itself win
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("User-Agent", "robots-agent")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+	if got.Header.Get("User-Agent") != "robots-agent" {
+		t.Errorf("expected the request's own user agent, got %q", got.Header.Get("User-Agent"))
+	}
+}
+
+func TestClient_MaxRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Each hop adds a character until /aaa
+		if len(r.URL.Path) < 4 {
+			http.Redirect(w, r, r.URL.Path+"a", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		maxRedirects int
+		ok           bool
+	}{
+		{3, true},
+		{2, false},
+		{0, false},
+	}
+
+	for _, tt := range tests {
+		opts := DefaultClientOptions()
+		opts.MaxRedirects = tt.maxRedirects
+		client, _ := NewClient(opts)
+
+		_, err := client.Scrape(server.URL + "/")
+		if tt.ok && err != nil {
+			t.Errorf("MaxRedirects %d: unexpected error %v", tt.maxRedirects, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrTooManyRedirects) {
+			t.Errorf("MaxRedirects %d: expected ErrTooManyRedirects, got %v", tt.maxRedirects, err)
+		}
+	}
+
+	// The checker reports the chain as its own category without retrying
+	opts := DefaultClientOptions()
+	opts.MaxRedirects = 1
+	client, _ := NewClient(opts)
+	urls := []string{server.URL + "/"}
+	result := CheckLinks(&urls, CheckOptions{Concurrency: 1, PerHost: 1, Client: client, Retry: DefaultRetryPolicy()})[0]
+	if !result.Dead || result.Category != CategoryRedirect || result.Attempts != 1 {
+		t.Errorf("expected a dead redirect result on the first attempt, got %+v", result)
+	}
+}
+
+func TestClient_Proxy(t *testing.T) {
+	// The proxy receives the absolute url of the target
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	opts := DefaultClientOptions()
+	opts.Proxy = proxy.URL
+	client, err := NewClient(opts)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	body, err := client.Scrape("http://unreachable.invalid/page")
+	if err != nil || body != "via proxy" {
+		t.Fatalf("expected the response from the proxy, got %q, %v", body, err)
+	}
+	if proxied != "http://unreachable.invalid/page" {
+		t.Errorf("expected the proxy to receive the target url, got %q", proxied)
+	}
+
+	for _, bad := range []string{"not a url", "://missing-scheme"} {
+		opts.Proxy = bad
+		if _, err := NewClient(opts); err == nil {
+			t.Errorf("expected an error for proxy %q", bad)
+		}
+	}
+}
+
+func TestClient_ResponseTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	opts := DefaultClientOptions()
+	opts.ResponseTimeout = 50 * time.Millisecond
+	client, _ := NewClient(opts)
+
+	urls := []string{server.URL}
+	result := CheckLinks(&urls, CheckOptions{Concurrency: 1, PerHost: 1, Client: client})[0]
+	if !result.Dead || result.Category != CategoryTimeout {
+		t.Errorf("expected a timeout, got %+v", result)
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders([]string{"x-one: 1", "X-One: 2", "Authorization:  Bearer a:b "})
+	if err != nil {
+		t.Fatalf("ParseHeaders() error = %v", err)
+	}
+	if strings.Join(headers.Values("X-One"), ",") != "1,2" {
+		t.Errorf("expected repeated headers to accumulate, got %v", headers.Values("X-One"))
+	}
+	if headers.Get("Authorization") != "Bearer a:b" {
+		t.Errorf("expected the value after the first colon, got %q", headers.Get("Authorization"))
+	}
+
+	for _, bad := range []string{"no-colon", ": empty-name", "Bad Name: x"} {
+		if _, err := ParseHeaders([]string{bad}); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ConfigFileName is the config file looked for in the working directory
+const ConfigFileName = ".deadlinks.json"
+
+// Config holds the settings read from a config file. Keys are the long
+// names of the check command's flags; each value is kept as the strings
+// that would be given on the command line, one per element of a list.
+type Config struct {
+	Values map[string][]string
+	lists  map[string]bool // keys whose value was written as a list
+}
+
+// LoadConfig reads a JSON object of flag names to values. Values may be
+// strings, numbers, booleans or lists of those.
+func LoadConfig(r io.Reader) (*Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// Keep numbers as written, so 0.5 and 10 are not reformatted
+	decoder.UseNumber()
+
+	var raw map[string]any
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("invalid config: unexpected content after the top level object")
+	}
+
+	config := &Config{Values: make(map[string][]string), lists: make(map[string]bool)}
+	for key, value := range raw {
+		if list, ok := value.([]any); ok {
+			config.lists[key] = true
+			config.Values[key] = []string{}
+			for _, element := range list {
+				s, err := configScalar(key, element)
+				if err != nil {
+					return nil, err
+				}
+				config.Values[key] = append(config.Values[key], s)
+			}
+			continue
+		}
+		s, err := configScalar(key, value)
+		if err != nil {
+			return nil, err
+		}
+		config.Values[key] = []string{s}
+	}
+	return config, nil
+}
+
+// configScalar converts a single JSON value to its command line form
+func configScalar(key string, value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("config key %q: expected a string, number, boolean or a list of those", key)
+	}
+}
+
+// Keys returns the config keys in sorted order
+func (c *Config) Keys() []string {
+	var keys []string
+	for key := range c.Values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// IsList reports whether the value of key was written as a list
+func (c *Config) IsList(key string) bool {
+	return c.lists[key]
+}
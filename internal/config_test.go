@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	config, err := LoadConfig(strings.NewReader(`{
+		"depth": 3,
+		"rate": 0.5,
+		"subdomains": true,
+		"timeout": "20s",
+		"exclude": ["/blog/*", "re:[?&]page=\\d+"],
+		"kind": []
+	}`))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	expected := map[string]string{
+		"depth":      "3",
+		"rate":       "0.5",
+		"subdomains": "true",
+		"timeout":    "20s",
+		"exclude":    `/blog/*|re:[?&]page=\d+`,
+		"kind":       "",
+	}
+	for key, value := range expected {
+		if got := strings.Join(config.Values[key], "|"); got != value {
+			t.Errorf("%s = %q, expected %q", key, got, value)
+		}
+	}
+	if strings.Join(config.Keys(), ",") != "depth,exclude,kind,rate,subdomains,timeout" {
+		t.Errorf("expected sorted keys, got %v", config.Keys())
+	}
+	if !config.IsList("exclude") || !config.IsList("kind") || config.IsList("depth") {
+		t.Errorf("expected only list values to be lists")
+	}
+
+	invalid := []string{
+		``,
+		`[1, 2]`,
+		`{"depth": 1} {}`,
+		`{"header": {"X-Token": "a"}}`,
+		`{"exclude": [["nested"]]}`,
+		`{"proxy": null}`,
+	}
+	for _, input := range invalid {
+		if _, err := LoadConfig(strings.NewReader(input)); err == nil {
+			t.Errorf("LoadConfig(%q) expected an error", input)
+		}
+	}
+}
@@ -1,62 +1,419 @@
 package internal
 
 import (
+	"fmt"
 	"net/url"
+	"sync"
+	"time"
 )
 
-func CrawlSite(startURL string, maxDepth int) []string {
-	visited := make(map[string]bool)
-	collected := make(map[string]bool)
-	var allLinks []string
+// LinkSource records where on the site a link was found
+type LinkSource struct {
+	Page      string // url of the page containing the link
+	Original  string // the link exactly as written, before resolving and normalizing
+	Element   string
+	Attribute string
+	Kind      string
+	Text      string
+	Line      int
+	Column    int
+}
 
-	// Call recursive function
-	crawlRecursive(startURL, startURL, 0, maxDepth, visited, &allLinks, collected)
+// CrawlResult holds every link discovered during a crawl
+type CrawlResult struct {
+	Links          []string                // unique absolute urls in discovery order
+	Sources        map[string][]LinkSource // every place each url was found
+	RobotsBlocked  []string                // urls skipped because robots.txt disallows them
+	SitemapEntries []string                // unique urls listed in the sitemaps read
+	SitemapErrors  []string                // sitemaps that could not be fetched or parsed
+	StartError     string                  // why the start page could not be fetched, "" on success
+	Anchors        PageAnchors             // fragment targets of every fetched page
+	Spellings      map[string]string       // first spelling written of each link that normalizing changed
+}
 
-	return allLinks
+// SitemapSet returns the sitemap entries as a set for quick lookup
+func (r *CrawlResult) SitemapSet() map[string]bool {
+	set := make(map[string]bool)
+	for _, entry := range r.SitemapEntries {
+		set[entry] = true
+	}
+	return set
+}
 
+// CrawlOptions controls how a site is crawled
+type CrawlOptions struct {
+	MaxDepth     int          // deepest level fetched, 0 = start page only
+	Workers      int          // pages fetched at once
+	RobotsAgent  string       // user-agent token matched against robots.txt groups
+	IgnoreRobots bool         // fetch and check everything regardless of robots.txt
+	UseSitemaps  bool         // seed the crawl from the site's sitemaps
+	SitemapURLs  []string     // sitemaps to read instead of discovering them
+	Kinds        []string     // link kinds to check, empty for all
+	Limiter      *RateLimiter // shared with the checker; nil applies only Crawl-delay
+	Client       *Client      // shared with the checker; nil for the defaults
+	Normalizer   *Normalizer  // canonicalizes urls; nil only strips fragments for fetching
+	Scope        *Scope       // pages worth crawling; nil for every page on the start host
 }
 
-func crawlRecursive(currentURL, baseURL string, depth, maxDepth int, visited map[string]bool, allLinks *[]string, collected map[string]bool) {
-	// Stop if too deep
-	if depth > maxDepth {
-		return
+// DefaultCrawlOptions returns the options used when none are given
+func DefaultCrawlOptions() CrawlOptions {
+	return CrawlOptions{
+		MaxDepth:    2,
+		Workers:     8,
+		RobotsAgent: "dead-link-checker",
+		Normalizer:  NewNormalizer(DefaultNormalizeOptions()),
 	}
+}
 
-	// Stop if URL has already been visited
-	if visited[currentURL] {
-		return
+func newCrawlResult() *CrawlResult {
+	return &CrawlResult{
+		Links:     []string{},
+		Sources:   make(map[string][]LinkSource),
+		Anchors:   make(PageAnchors),
+		Spellings: make(map[string]string),
 	}
+}
 
-	// Mark url as visited
-	visited[currentURL] = true
+// crawler holds the state of one crawl
+type crawler struct {
+	opts     CrawlOptions
+	startURL string
+	robots   *robotsCache // nil when robots.txt is ignored
+	limiter  *RateLimiter
+	scope    *Scope
+	kinds    map[string]bool // kinds of link to check, nil for all
 
-	// Use scrape function to get HTML string
-	htmlContent, err := Scrape(currentURL)
-	if err != nil {
-		return // Skip page if it can't be scraped
+	// Only the merge loop touches these, never the fetch workers
+	result    *CrawlResult
+	visited   map[string]bool
+	collected map[string]bool
+
+	// Written while merging and read by the fetch workers, never both at once
+	spellings map[string]string // page to fetch for each visited page, when normalizing changed it
+}
+
+func newCrawler(startURL string, opts CrawlOptions) *crawler {
+	written := pageKey(startURL)
+	startURL = opts.Normalizer.PageURL(startURL)
+	c := &crawler{
+		opts:      opts,
+		startURL:  startURL,
+		limiter:   opts.Limiter,
+		scope:     opts.Scope,
+		result:    newCrawlResult(),
+		visited:   map[string]bool{startURL: true},
+		collected: make(map[string]bool),
+		spellings: make(map[string]string),
+	}
+	c.spell(startURL, written)
+	if c.limiter == nil {
+		c.limiter = NewRateLimiter(0, 0)
+	}
+	if c.scope == nil {
+		// Without patterns this cannot fail
+		c.scope, _ = NewScope(startURL, ScopeOptions{})
+	}
+	if !opts.IgnoreRobots {
+		c.robots = newRobotsCache(opts.RobotsAgent, opts.Client)
 	}
+	if len(opts.Kinds) > 0 {
+		c.kinds = make(map[string]bool)
+		for _, kind := range opts.Kinds {
+			c.kinds[kind] = true
+		}
+	}
+	return c
+}
 
-	// Parse the links from web
-	links, err := ParseLinks(htmlContent)
-	if err != nil {
-		return
+// spell records written as the spelling to request for the normalized
+// url, unless one was recorded first or normalizing left it unchanged
+func spell(spellings map[string]string, normalized, written string) {
+	if _, ok := spellings[normalized]; !ok && written != normalized {
+		spellings[normalized] = written
 	}
+}
 
-	for _, link := range links {
-		absoluteURL := resolveURL(link, currentURL)
-		if absoluteURL != "" {
-			if collected[absoluteURL] {
+// spell records the spelling to fetch the normalized page url with
+func (c *crawler) spell(pageURL, written string) {
+	spell(c.spellings, pageURL, written)
+}
+
+// allowed reports whether robots.txt lets us request rawURL. robots.txt
+// governs crawling, so it only applies to the site's own hosts; links to
+// other sites are checked whatever their robots.txt says.
+func (c *crawler) allowed(rawURL string) bool {
+	return c.robots == nil || !c.scope.Internal(rawURL) || c.robots.allowed(rawURL)
+}
+
+// followElements are the elements whose links lead to pages worth crawling
+var followElements = map[string]bool{
+	"a":       true,
+	"area":    true,
+	"iframe":  true,
+	"sitemap": true,
+}
+
+// CrawlSite walks the site breadth-first from startURL. Each level of the
+// frontier is fetched concurrently, then merged in frontier order, so a
+// page's depth is always its shortest distance from the start page and the
+// result does not depend on which fetch finishes first.
+func CrawlSite(startURL string, opts CrawlOptions) *CrawlResult {
+	c := newCrawler(startURL, opts)
+	startURL = c.startURL
+
+	frontier := []string{startURL}
+	if opts.MaxDepth >= 0 && !c.allowed(startURL) {
+		c.result.RobotsBlocked = append(c.result.RobotsBlocked, startURL)
+		c.result.StartError = "disallowed by robots.txt"
+		frontier = nil
+	}
+
+	// Pages listed in sitemaps start alongside the start page
+	if opts.MaxDepth >= 0 && (opts.UseSitemaps || len(opts.SitemapURLs) > 0) {
+		frontier = append(frontier, c.admit(c.readSitemaps())...)
+	}
+
+	for depth := 0; depth <= opts.MaxDepth && len(frontier) > 0; depth++ {
+		pages, errs := c.fetchPages(frontier)
+		// The start page is always first in the first level
+		if depth == 0 && len(frontier) > 0 && frontier[0] == startURL && errs[0] != nil {
+			c.result.StartError = errs[0].Error()
+		}
+
+		// Links and pages seen for the first time on this level
+		var found, candidates []string
+		for i, pageURL := range frontier {
+			if pages[i] == nil {
 				continue
-			} else {
-				*allLinks = append(*allLinks, absoluteURL)
-				collected[absoluteURL] = true
 			}
+			c.result.Anchors.add(pageURL, pages[i].Anchors)
+			// Relative links resolve against where the page was served from
+			pageFound, pageCandidates := c.merge(pageURL, baseURL(pages[i].URL, pages[i].BaseHref), pages[i].Links)
+			found = append(found, pageFound...)
+			candidates = append(candidates, pageCandidates...)
+		}
+
+		frontier = c.admit(found, candidates)
+	}
+
+	return c.result
+}
+
+// merge records the links found on one page, resolving them against base.
+// It returns the links seen for the first time, which need checking, and
+// the unvisited internal pages they lead to, which need crawling. Anchors
+// are followed even when their kind is not being checked, so filtering
+// never shrinks the crawl. The canonical form only groups spellings; the
+// first spelling of each url is the one requested.
+func (c *crawler) merge(pageURL, base string, links []Link) (found, candidates []string) {
+	for _, link := range links {
+		written := resolveURL(link.URL, base)
+		if written == "" {
+			continue
+		}
+		// Every spelling of a url is collected once, under its canonical form
+		absoluteURL := c.opts.Normalizer.Normalize(written)
 
-			if isInternalLink(absoluteURL, baseURL) {
-				crawlRecursive(absoluteURL, baseURL, depth+1, maxDepth, visited, allLinks, collected)
+		if c.kinds == nil || c.kinds[link.Kind] {
+			// Record every page a link appears on, even once collected
+			c.result.Sources[absoluteURL] = append(c.result.Sources[absoluteURL], LinkSource{
+				Page:      pageURL,
+				Original:  link.URL,
+				Element:   link.Element,
+				Attribute: link.Attribute,
+				Kind:      link.Kind,
+				Text:      link.Text,
+				Line:      link.Line,
+				Column:    link.Column,
+			})
+
+			if !c.collected[absoluteURL] {
+				c.collected[absoluteURL] = true
+				spell(c.result.Spellings, absoluteURL, written)
+				found = append(found, absoluteURL)
 			}
 		}
+
+		// Pages are fetched once whatever fragment links to them
+		nextPage := c.opts.Normalizer.PageURL(absoluteURL)
+		if followElements[link.Element] && c.scope.Crawlable(nextPage) && !c.visited[nextPage] {
+			c.visited[nextPage] = true
+			c.spell(nextPage, pageKey(written))
+			candidates = append(candidates, nextPage)
+		}
 	}
+	return found, candidates
+}
+
+// admit applies robots.txt to newly found links and pages, adds the allowed
+// links to the result and returns the allowed pages to crawl next.
+func (c *crawler) admit(found, candidates []string) []string {
+	// Load robots.txt for any new internal hosts in one concurrent batch
+	if c.robots != nil {
+		var internal []string
+		for _, rawURL := range append(append([]string{}, found...), candidates...) {
+			if c.scope.Internal(rawURL) {
+				internal = append(internal, rawURL)
+			}
+		}
+		c.robots.prefetch(internal, c.opts.Workers)
+	}
+
+	for _, absoluteURL := range found {
+		if !c.allowed(absoluteURL) {
+			c.result.RobotsBlocked = append(c.result.RobotsBlocked, absoluteURL)
+			continue
+		}
+		c.result.Links = append(c.result.Links, absoluteURL)
+	}
+
+	var next []string
+	for _, pageURL := range candidates {
+		if c.allowed(pageURL) {
+			next = append(next, pageURL)
+		}
+	}
+	return next
+}
+
+// readSitemaps loads the configured sitemaps, or discovers them from
+// robots.txt and falls back to /sitemap.xml, and merges the listed urls
+// as if each sitemap were a page linking to them.
+func (c *crawler) readSitemaps() (found, candidates []string) {
+	sitemapURLs := c.opts.SitemapURLs
+	if len(sitemapURLs) == 0 {
+		sitemapURLs = discoverSitemaps(c.opts.Client, c.startURL, c.robots, c.opts.RobotsAgent)
+	}
+
+	pages, failures := collectSitemaps(c.opts.Client, sitemapURLs, c.limiter)
+	c.result.SitemapErrors = append(c.result.SitemapErrors, failures...)
+
+	listed := make(map[string]bool)
+	for _, page := range pages {
+		absoluteURL := c.opts.Normalizer.Normalize(resolveURL(page.entry.URL, page.sitemapURL))
+		if absoluteURL == "" {
+			continue
+		}
+		if !listed[absoluteURL] {
+			listed[absoluteURL] = true
+			c.result.SitemapEntries = append(c.result.SitemapEntries, absoluteURL)
+		}
+
+		pageFound, pageCandidates := c.merge(page.sitemapURL, page.sitemapURL, []Link{{
+			URL:       page.entry.URL,
+			Element:   "sitemap",
+			Attribute: "loc",
+			Kind:      KindSitemap,
+			Line:      page.entry.Line,
+			Column:    page.entry.Column,
+		}})
+		found = append(found, pageFound...)
+		candidates = append(candidates, pageCandidates...)
+	}
+	return found, candidates
+}
+
+// discoverSitemaps returns the Sitemap lines from the start host's
+// robots.txt, or the conventional /sitemap.xml when there are none.
+func discoverSitemaps(client *Client, startURL string, robots *robotsCache, agent string) []string {
+	key := robotsKey(startURL)
+	if key == "" {
+		return nil
+	}
+
+	var rules *Robots
+	if robots != nil {
+		rules = robots.lookup(startURL)
+	} else {
+		// robots.txt is ignored for crawling but still lists sitemaps
+		rules = fetchRobots(client, key+"/robots.txt", agent)
+	}
+	if rules != nil && len(rules.Sitemaps) > 0 {
+		return rules.Sitemaps
+	}
+	return []string{key + "/sitemap.xml"}
+}
+
+// fetchPages scrapes and parses every url using a pool of workers. The
+// page for urls[i] ends up in the returned slice at index i; pages that
+// cannot be scraped or parsed are nil, with the reason at errs[i].
+func (c *crawler) fetchPages(urls []string) (pages []*Page, errs []error) {
+	pages = make([]*Page, len(urls))
+	errs = make([]error, len(urls))
+	var wg sync.WaitGroup
+
+	workers := c.opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Queue of indexes into urls
+	jobs := make(chan int)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				// Honour the rate limit and Crawl-delay between pages on the same host
+				var crawlDelay time.Duration
+				if c.robots != nil {
+					crawlDelay = c.robots.lookup(urls[i]).crawlDelay()
+				}
+				c.limiter.Wait(hostOf(urls[i]), crawlDelay)
+				pages[i], errs[i] = c.fetchPage(urls[i])
+			}
+		}()
+	}
+
+	for i := range urls {
+		jobs <- i
+	}
+	close(jobs)
+
+	wg.Wait()
+	return pages, errs
+}
+
+// fetchPage returns the parsed contents of a single page, requested as it
+// was first written. A page that redirects out of the crawl is not parsed,
+// since its links belong to another site.
+func (c *crawler) fetchPage(pageURL string) (*Page, error) {
+	written := pageURL
+	if spelling, ok := c.spellings[pageURL]; ok {
+		written = spelling
+	}
+	// Use scrape function to get HTML string
+	htmlContent, finalURL, err := c.opts.Client.ScrapePage(written)
+	if err != nil {
+		return nil, err // Skip page if it can't be scraped
+	}
+	if finalPage := c.opts.Normalizer.PageURL(finalURL); finalPage != pageURL && !c.scope.Crawlable(finalPage) {
+		return nil, fmt.Errorf("redirected outside the crawl to %s", finalURL)
+	}
+
+	// Parse the links from web
+	page, err := ParsePage(htmlContent)
+	if err != nil {
+		return nil, err
+	}
+	page.URL = finalURL
+	return page, nil
+}
+
+// baseURL returns the url a page's relative links resolve against: its
+// <base> href resolved against the page, or the page itself when there is
+// no base or the base is malformed or not http(s).
+func baseURL(pageURL, baseHref string) string {
+	if baseHref == "" {
+		return pageURL
+	}
+	resolved := resolveURL(baseHref, pageURL)
+	parsed, err := url.Parse(resolved)
+	if resolved == "" || err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return pageURL
+	}
+	return resolved
 }
 
 func resolveURL(link, baseURL string) string {
@@ -75,17 +432,3 @@ func resolveURL(link, baseURL string) string {
 	// Combine base URL with the relative link
 	return base.ResolveReference(rel).String()
 }
-
-func isInternalLink(link, baseURL string) bool {
-	linkURL, err := url.Parse(link)
-	if err != nil {
-		return false
-	}
-
-	baseURLParsed, err := url.Parse(baseURL)
-	if err != nil {
-		return false
-	}
-
-	return linkURL.Hostname() == baseURLParsed.Hostname()
-}
@@ -1,6 +1,11 @@
 package internal
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 )
 
@@ -71,7 +76,31 @@ func TestResolveURL(t *testing.T) {
 	}
 }
 
-func TestIsInternalLink(t *testing.T) {
+func TestBaseURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		pageURL  string
+		baseHref string
+		expected string
+	}{
+		{"no base", "https://example.com/docs/page", "", "https://example.com/docs/page"},
+		{"absolute base", "https://example.com/docs/page", "https://cdn.example.com/v2/", "https://cdn.example.com/v2/"},
+		{"root relative base", "https://example.com/docs/guide/page", "/docs/", "https://example.com/docs/"},
+		{"relative base", "https://example.com/docs/guide/page", "../api/", "https://example.com/docs/api/"},
+		{"malformed base", "https://example.com/docs/page", "ht tp://bad base", "https://example.com/docs/page"},
+		{"non http base", "https://example.com/docs/page", "javascript:void(0)", "https://example.com/docs/page"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := baseURL(tt.pageURL, tt.baseHref); got != tt.expected {
+				t.Errorf("baseURL(%q, %q) = %q, expected %q", tt.pageURL, tt.baseHref, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestScope_Internal(t *testing.T) {
 	tests := []struct {
 		name     string
 		link     string
@@ -142,52 +171,15 @@ func TestIsInternalLink(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := isInternalLink(tt.link, tt.baseURL)
+			scope, _ := NewScope(tt.baseURL, ScopeOptions{})
+			result := scope.Internal(tt.link)
 			if result != tt.expected {
-				t.Errorf("isInternalLink(%q, %q) = %v, expected %v", tt.link, tt.baseURL, result, tt.expected)
+				t.Errorf("Internal(%q) from %q = %v, expected %v", tt.link, tt.baseURL, result, tt.expected)
 			}
 		})
 	}
 }
 
-func TestCrawlRecursive(t *testing.T) {
-	// Note: These tests would require mocking the Scrape and ParseLinks functions
-	// For now, we'll test the basic structure and edge cases
-
-	t.Run("max depth exceeded", func(t *testing.T) {
-		visited := make(map[string]bool)
-		collected := make(map[string]bool)
-		var allLinks []string
-
-		// Call with depth > maxDepth
-		crawlRecursive("https://example.com", "https://example.com", 2, 1, visited, &allLinks, collected)
-
-		// Should not add any links since depth exceeds maxDepth
-		if len(allLinks) != 0 {
-			t.Errorf("Expected no links when depth exceeds maxDepth, got %d links", len(allLinks))
-		}
-
-		// Should not mark URL as visited
-		if visited["https://example.com"] {
-			t.Errorf("Expected URL not to be marked as visited when depth exceeds maxDepth")
-		}
-	})
-
-	t.Run("already visited URL", func(t *testing.T) {
-		visited := make(map[string]bool)
-		visited["https://example.com"] = true
-		collected := make(map[string]bool)
-		var allLinks []string
-
-		crawlRecursive("https://example.com", "https://example.com", 0, 2, visited, &allLinks, collected)
-
-		// Should not process already visited URL
-		if len(allLinks) != 0 {
-			t.Errorf("Expected no links when URL already visited, got %d links", len(allLinks))
-		}
-	})
-}
-
 func TestCrawlSite(t *testing.T) {
 	t.Run("basic structure", func(t *testing.T) {
 		// This test verifies the basic structure of CrawlSite
@@ -196,10 +188,10 @@ func TestCrawlSite(t *testing.T) {
 		startURL := "https://example.com"
 		maxDepth := 1
 
-		result := CrawlSite(startURL, maxDepth)
+		result := CrawlSite(startURL, CrawlOptions{MaxDepth: maxDepth, Workers: 1})
 
 		// Result should be a slice (even if empty due to network issues in test)
-		if result == nil {
+		if result == nil || result.Links == nil {
 			t.Errorf("Expected non-nil result from CrawlSite")
 		}
 	})
@@ -208,11 +200,11 @@ func TestCrawlSite(t *testing.T) {
 		startURL := "not-a-valid-url"
 		maxDepth := 1
 
-		result := CrawlSite(startURL, maxDepth)
+		result := CrawlSite(startURL, CrawlOptions{MaxDepth: maxDepth, Workers: 1})
 
 		// Should return empty slice for invalid URL
-		if len(result) != 0 {
-			t.Errorf("Expected empty result for invalid start URL, got %d links", len(result))
+		if len(result.Links) != 0 {
+			t.Errorf("Expected empty result for invalid start URL, got %d links", len(result.Links))
 		}
 	})
 
@@ -220,10 +212,10 @@ func TestCrawlSite(t *testing.T) {
 		startURL := "https://example.com"
 		maxDepth := 0
 
-		result := CrawlSite(startURL, maxDepth)
+		result := CrawlSite(startURL, CrawlOptions{MaxDepth: maxDepth, Workers: 1})
 
 		// Should still process the start URL at depth 0
-		if result == nil {
+		if result == nil || result.Links == nil {
 			t.Errorf("Expected non-nil result even with maxDepth 0")
 		}
 	})
@@ -232,13 +224,514 @@ func TestCrawlSite(t *testing.T) {
 		startURL := "https://example.com"
 		maxDepth := -1
 
-		result := CrawlSite(startURL, maxDepth)
+		result := CrawlSite(startURL, CrawlOptions{MaxDepth: maxDepth, Workers: 1})
 
 		// Should return empty slice since depth 0 > maxDepth -1
-		if len(result) != 0 {
-			t.Errorf("Expected empty result for negative maxDepth, got %d links", len(result))
+		if len(result.Links) != 0 {
+			t.Errorf("Expected empty result for negative maxDepth, got %d links", len(result.Links))
+		}
+	})
+}
+
+func TestCrawlSite_Sources(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "<html><body>\n<a href=\"/about\">About us</a>\n<a href=\"/missing\">Broken</a>\n</body></html>")
+	})
+	mux.HandleFunc("/about", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body><a href=\"/missing\">Also broken</a></body></html>")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	result := CrawlSite(server.URL+"/", CrawlOptions{MaxDepth: 2, Workers: 4})
+
+	expectedLinks := []string{server.URL + "/about", server.URL + "/missing"}
+	if len(result.Links) != len(expectedLinks) {
+		t.Fatalf("expected links %v, got %v", expectedLinks, result.Links)
+	}
+	for i := range expectedLinks {
+		if result.Links[i] != expectedLinks[i] {
+			t.Errorf("expected link %d to be %q, got %q", i, expectedLinks[i], result.Links[i])
+		}
+	}
+
+	// The missing page is linked from both pages, homepage first
+	sources := result.Sources[server.URL+"/missing"]
+	if len(sources) != 2 {
+		t.Fatalf("expected 2 sources for missing page, got %+v", sources)
+	}
+	home := LinkSource{Page: server.URL + "/", Original: "/missing", Element: "a", Attribute: "href", Kind: KindAnchor, Text: "Broken", Line: 3, Column: 1}
+	if sources[0] != home {
+		t.Errorf("expected first source %+v, got %+v", home, sources[0])
+	}
+	if sources[1].Page != server.URL+"/about" || sources[1].Text != "Also broken" {
+		t.Errorf("expected second source on about page, got %+v", sources[1])
+	}
+}
+
+// newSiteServer serves each path in pages as an HTML page linking to the
+// listed hrefs; any other path is a 404.
+func newSiteServer(pages map[string][]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hrefs, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "<html><body>")
+		for _, href := range hrefs {
+			fmt.Fprintf(w, "<a href=%q>link</a>\n", href)
+		}
+		fmt.Fprint(w, "</body></html>")
+	}))
+}
+
+// crawlDepthFirst is the original recursive crawler, kept as a reference
+// to compare the breadth-first crawler against.
+func crawlDepthFirst(currentURL, baseURL string, depth, maxDepth int, visited, collected map[string]bool) {
+	if depth > maxDepth || visited[currentURL] {
+		return
+	}
+	visited[currentURL] = true
+
+	page, err := newCrawler(baseURL, CrawlOptions{IgnoreRobots: true}).fetchPage(currentURL)
+	if err != nil {
+		return
+	}
+	for _, link := range page.Links {
+		absoluteURL := resolveURL(link.URL, currentURL)
+		if absoluteURL == "" || collected[absoluteURL] {
+			continue
+		}
+		collected[absoluteURL] = true
+		if scope, _ := NewScope(baseURL, ScopeOptions{}); scope.Internal(absoluteURL) {
+			crawlDepthFirst(absoluteURL, baseURL, depth+1, maxDepth, visited, collected)
+		}
+	}
+}
+
+func TestCrawlSite_MatchesDepthFirst(t *testing.T) {
+	fixtures := map[string]map[string][]string{
+		"tree": {
+			"/":           {"/docs/", "/blog/", "https://external.invalid/page"},
+			"/docs/":      {"intro", "setup", "../"},
+			"/blog/":      {"/blog/2024", "/blog/2025", "#top"},
+			"/docs/intro": {"/docs/advanced", "/missing"},
+			"/blog/2024":  {"/blog/2024/post"},
+		},
+		"cycle": {
+			"/":  {"/a"},
+			"/a": {"/b", "/"},
+			"/b": {"/a", "/c", "/b"},
+			"/c": {"/d"},
+		},
+	}
+
+	for name, pages := range fixtures {
+		for maxDepth := -1; maxDepth <= 3; maxDepth++ {
+			t.Run(fmt.Sprintf("%s depth %d", name, maxDepth), func(t *testing.T) {
+				server := newSiteServer(pages)
+				defer server.Close()
+				startURL := server.URL + "/"
+
+				expected := make(map[string]bool)
+				crawlDepthFirst(startURL, startURL, 0, maxDepth, make(map[string]bool), expected)
+
+				result := CrawlSite(startURL, CrawlOptions{MaxDepth: maxDepth, Workers: 4})
+				if len(result.Links) != len(expected) {
+					t.Errorf("expected %d links, got %d: %v", len(expected), len(result.Links), result.Links)
+				}
+				for _, link := range result.Links {
+					if !expected[link] {
+						t.Errorf("unexpected link %q", link)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestCrawlSite_ShortestPathDepth(t *testing.T) {
+	// /b is one hop from the homepage, but depth-first reaches it first
+	// through /a at depth 2 and never crawls its child /c
+	server := newSiteServer(map[string][]string{
+		"/":  {"/a", "/b"},
+		"/a": {"/b"},
+		"/b": {"/c"},
+		"/c": {"/d"},
+	})
+	defer server.Close()
+	startURL := server.URL + "/"
+
+	depthFirst := make(map[string]bool)
+	crawlDepthFirst(startURL, startURL, 0, 2, make(map[string]bool), depthFirst)
+	if depthFirst[server.URL+"/d"] {
+		t.Fatalf("expected the depth-first reference to miss /d")
+	}
+
+	result := CrawlSite(startURL, CrawlOptions{MaxDepth: 2, Workers: 2})
+	expected := []string{server.URL + "/a", server.URL + "/b", server.URL + "/c", server.URL + "/d"}
+	if len(result.Links) != len(expected) {
+		t.Fatalf("expected links %v, got %v", expected, result.Links)
+	}
+	for i := range expected {
+		if result.Links[i] != expected[i] {
+			t.Errorf("expected link %d to be %q, got %q", i, expected[i], result.Links[i])
+		}
+	}
+}
+
+func TestFetchPages(t *testing.T) {
+	server := newSiteServer(map[string][]string{
+		"/one": {"/x"},
+		"/two": {"/y", "/z"},
+	})
+	defer server.Close()
+
+	urls := []string{server.URL + "/one", server.URL + "/missing", server.URL + "/two"}
+	pages, errs := newCrawler(server.URL, CrawlOptions{IgnoreRobots: true}).fetchPages(urls)
+
+	if len(pages) != 3 {
+		t.Fatalf("expected 3 pages, got %d", len(pages))
+	}
+	if len(pages[0].Links) != 1 || pages[0].Links[0].URL != "/x" {
+		t.Errorf("expected links of /one at index 0, got %+v", pages[0])
+	}
+	if pages[1] != nil || errs[1] == nil {
+		t.Errorf("expected no page and an error for missing url, got %+v and %v", pages[1], errs[1])
+	}
+	if errs[0] != nil || errs[2] != nil {
+		t.Errorf("expected no errors for existing pages, got %v", errs)
+	}
+	if len(pages[2].Links) != 2 || pages[2].Links[1].URL != "/z" {
+		t.Errorf("expected links of /two at index 2, got %+v", pages[2])
+	}
+}
+
+func TestCrawlSite_Robots(t *testing.T) {
+	robotsTxt := "User-agent: *\nDisallow: /private\nAllow: /private/open\n"
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, robotsTxt)
+	})
+	fetched := make(chan string, 10)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fetched <- r.URL.Path
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/private/secret">s</a><a href="/private/open">o</a><a href="/public">p</a>`)
+		case "/private/secret":
+			fmt.Fprint(w, `<a href="/hidden">h</a>`)
+		default:
+			fmt.Fprint(w, `<html></html>`)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	startURL := server.URL + "/"
+
+	t.Run("honoured", func(t *testing.T) {
+		result := CrawlSite(startURL, CrawlOptions{MaxDepth: 2, Workers: 2, RobotsAgent: "dead-link-checker"})
+
+		expectedLinks := []string{server.URL + "/private/open", server.URL + "/public"}
+		if len(result.Links) != len(expectedLinks) || result.Links[0] != expectedLinks[0] || result.Links[1] != expectedLinks[1] {
+			t.Errorf("expected links %v, got %v", expectedLinks, result.Links)
+		}
+		if len(result.RobotsBlocked) != 1 || result.RobotsBlocked[0] != server.URL+"/private/secret" {
+			t.Errorf("expected the secret page to be blocked, got %v", result.RobotsBlocked)
+		}
+		// Blocked links still know where they were found
+		if len(result.Sources[server.URL+"/private/secret"]) != 1 {
+			t.Errorf("expected a source for the blocked link, got %+v", result.Sources[server.URL+"/private/secret"])
+		}
+	})
+
+	// Drain the pages fetched so far
+	for len(fetched) > 0 {
+		<-fetched
+	}
+
+	t.Run("ignored", func(t *testing.T) {
+		result := CrawlSite(startURL, CrawlOptions{MaxDepth: 2, Workers: 2, IgnoreRobots: true})
+
+		if len(result.RobotsBlocked) != 0 {
+			t.Errorf("expected nothing blocked, got %v", result.RobotsBlocked)
+		}
+		if len(result.Links) != 4 {
+			t.Errorf("expected 4 links including the hidden page, got %v", result.Links)
+		}
+	})
+
+	t.Run("start page disallowed", func(t *testing.T) {
+		robotsTxt = "User-agent: *\nDisallow: /\n"
+		for len(fetched) > 0 {
+			<-fetched
+		}
+
+		result := CrawlSite(startURL, CrawlOptions{MaxDepth: 2, Workers: 2, RobotsAgent: "dead-link-checker"})
+		if len(result.Links) != 0 || len(result.RobotsBlocked) != 1 || result.RobotsBlocked[0] != startURL {
+			t.Errorf("expected only the start page to be blocked, got links %v blocked %v", result.Links, result.RobotsBlocked)
+		}
+		if len(fetched) != 0 {
+			t.Errorf("expected no pages fetched, got %d", len(fetched))
+		}
+	})
+}
+
+func TestCrawlSite_RobotsExternal(t *testing.T) {
+	// The other site's robots.txt fails, which would block all of it
+	var robotsRequests atomic.Int32
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsRequests.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer external.Close()
+	// A different hostname makes the server external to the crawled site
+	externalURL := strings.Replace(external.URL, "127.0.0.1", "localhost", 1) + "/page"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, `<a href="%s">other site</a>`, externalURL)
+		}
+	}))
+	defer server.Close()
+
+	result := CrawlSite(server.URL+"/", CrawlOptions{MaxDepth: 1, Workers: 2, RobotsAgent: "dead-link-checker"})
+	if len(result.Links) != 1 || result.Links[0] != externalURL {
+		t.Errorf("expected the external link to be checked, got %v", result.Links)
+	}
+	if len(result.RobotsBlocked) != 0 {
+		t.Errorf("expected nothing blocked, got %v", result.RobotsBlocked)
+	}
+	if robotsRequests.Load() != 0 {
+		t.Errorf("expected the external robots.txt not to be requested, got %d requests", robotsRequests.Load())
+	}
+}
+
+func TestCrawlSite_Sitemaps(t *testing.T) {
+	sitemapPath := "/sitemap.xml"
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		if sitemapPath != "/sitemap.xml" {
+			fmt.Fprintf(w, "User-agent: *\nDisallow: /private\nSitemap: http://%s%s\n", r.Host, sitemapPath)
+			return
+		}
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+	})
+	mux.HandleFunc(sitemapPath, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<urlset><url><loc>/orphan</loc></url><url><loc>/about</loc></url><url><loc>/private/x</loc></url></urlset>`)
+	})
+	mux.HandleFunc("/custom-sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<urlset><url><loc>/custom</loc></url></urlset>`)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/about">About</a>`)
+		case "/orphan":
+			fmt.Fprint(w, `<a href="/orphan-child">Child</a>`)
+		default:
+			fmt.Fprint(w, `<html></html>`)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	startURL := server.URL + "/"
+
+	t.Run("disabled by default", func(t *testing.T) {
+		result := CrawlSite(startURL, CrawlOptions{MaxDepth: 1, Workers: 2, RobotsAgent: "bot"})
+		if len(result.Links) != 1 || len(result.SitemapEntries) != 0 {
+			t.Errorf("expected only the linked page, got %v and entries %v", result.Links, result.SitemapEntries)
 		}
 	})
+
+	t.Run("falls back to sitemap.xml", func(t *testing.T) {
+		result := CrawlSite(startURL, CrawlOptions{MaxDepth: 1, Workers: 2, RobotsAgent: "bot", UseSitemaps: true})
+
+		expectedLinks := []string{server.URL + "/orphan", server.URL + "/about", server.URL + "/orphan-child"}
+		if len(result.Links) != len(expectedLinks) {
+			t.Fatalf("expected links %v, got %v", expectedLinks, result.Links)
+		}
+		for i := range expectedLinks {
+			if result.Links[i] != expectedLinks[i] {
+				t.Errorf("expected link %d to be %q, got %q", i, expectedLinks[i], result.Links[i])
+			}
+		}
+		if len(result.SitemapEntries) != 3 {
+			t.Errorf("expected 3 sitemap entries, got %v", result.SitemapEntries)
+		}
+		if len(result.RobotsBlocked) != 1 || result.RobotsBlocked[0] != server.URL+"/private/x" {
+			t.Errorf("expected private sitemap entry to be blocked, got %v", result.RobotsBlocked)
+		}
+
+		// The about page is both listed and linked
+		sources := result.Sources[server.URL+"/about"]
+		if len(sources) != 2 || sources[0].Element != "sitemap" || sources[0].Page != server.URL+"/sitemap.xml" || sources[1].Element != "a" {
+			t.Errorf("expected sitemap then anchor sources for about, got %+v", sources)
+		}
+	})
+
+	t.Run("discovered from robots.txt", func(t *testing.T) {
+		sitemapPath = "/custom-sitemap.xml"
+		defer func() { sitemapPath = "/sitemap.xml" }()
+
+		result := CrawlSite(startURL, CrawlOptions{MaxDepth: 0, Workers: 2, RobotsAgent: "bot", IgnoreRobots: true, UseSitemaps: true})
+		if len(result.SitemapEntries) != 1 || result.SitemapEntries[0] != server.URL+"/custom" {
+			t.Errorf("expected the robots.txt sitemap to be used, got %v", result.SitemapEntries)
+		}
+	})
+
+	t.Run("explicit sitemap with errors", func(t *testing.T) {
+		result := CrawlSite(startURL, CrawlOptions{MaxDepth: 0, Workers: 2, RobotsAgent: "bot",
+			SitemapURLs: []string{server.URL + "/custom-sitemap.xml", server.URL + "/nope.xml"}})
+		if len(result.SitemapEntries) != 1 || len(result.SitemapErrors) != 1 {
+			t.Errorf("expected one entry and one error, got %v and %v", result.SitemapEntries, result.SitemapErrors)
+		}
+	})
+}
+
+func TestCrawlSite_Kinds(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<img src="/logo.png" alt="Logo"><a href="/gallery">Gallery</a><link rel="stylesheet" href="/site.css">`)
+		case "/gallery":
+			fmt.Fprint(w, `<img src="/photo.jpg"><iframe src="/frame"></iframe>`)
+		case "/frame":
+			fmt.Fprint(w, `<img src="/framed.jpg">`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	startURL := server.URL + "/"
+
+	t.Run("all kinds", func(t *testing.T) {
+		result := CrawlSite(startURL, CrawlOptions{MaxDepth: 2, Workers: 2, IgnoreRobots: true})
+		expected := []string{"/logo.png", "/gallery", "/site.css", "/photo.jpg", "/frame", "/framed.jpg"}
+		if len(result.Links) != len(expected) {
+			t.Fatalf("expected %d links, got %v", len(expected), result.Links)
+		}
+		for i := range expected {
+			if result.Links[i] != server.URL+expected[i] {
+				t.Errorf("expected link %d to be %q, got %q", i, server.URL+expected[i], result.Links[i])
+			}
+		}
+		// Images are checked but never crawled as pages
+		if source := result.Sources[server.URL+"/logo.png"][0]; source.Kind != KindImage || source.Text != "Logo" {
+			t.Errorf("expected image source with alt text, got %+v", source)
+		}
+	})
+
+	t.Run("images only", func(t *testing.T) {
+		result := CrawlSite(startURL, CrawlOptions{MaxDepth: 2, Workers: 2, IgnoreRobots: true, Kinds: []string{KindImage}})
+		// Anchors and frames are still followed to find more images
+		expected := []string{"/logo.png", "/photo.jpg", "/framed.jpg"}
+		if len(result.Links) != len(expected) {
+			t.Fatalf("expected %d links, got %v", len(expected), result.Links)
+		}
+		for i := range expected {
+			if result.Links[i] != server.URL+expected[i] {
+				t.Errorf("expected link %d to be %q, got %q", i, server.URL+expected[i], result.Links[i])
+			}
+		}
+		if _, ok := result.Sources[server.URL+"/gallery"]; ok {
+			t.Errorf("expected no sources recorded for filtered anchors")
+		}
+	})
+}
+
+func TestCrawlSite_BaseElement(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs/guide/page":
+			fmt.Fprint(w, `<html><head><base href="/docs/"><base href="/ignored/"></head>
+<body><a href="intro">Intro</a><a href="/absolute">Absolute</a><img src="img/logo.png"></body></html>`)
+		case "/docs/broken-base":
+			fmt.Fprint(w, `<html><head><base href="ht tp://bad base"></head><body><a href="sibling">Sibling</a></body></html>`)
+		default:
+			fmt.Fprint(w, `<html></html>`)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	t.Run("base element", func(t *testing.T) {
+		result := CrawlSite(server.URL+"/docs/guide/page", CrawlOptions{MaxDepth: 0, Workers: 1, IgnoreRobots: true})
+		expected := []string{"/docs/intro", "/absolute", "/docs/img/logo.png"}
+		if len(result.Links) != len(expected) {
+			t.Fatalf("expected %d links, got %v", len(expected), result.Links)
+		}
+		for i := range expected {
+			if result.Links[i] != server.URL+expected[i] {
+				t.Errorf("expected link %d to be %q, got %q", i, server.URL+expected[i], result.Links[i])
+			}
+		}
+		// Sources still name the page, not the base
+		if page := result.Sources[server.URL+"/docs/intro"][0].Page; page != server.URL+"/docs/guide/page" {
+			t.Errorf("expected source page to be the document, got %q", page)
+		}
+	})
+
+	t.Run("malformed base falls back to the page", func(t *testing.T) {
+		result := CrawlSite(server.URL+"/docs/broken-base", CrawlOptions{MaxDepth: 0, Workers: 1, IgnoreRobots: true})
+		if len(result.Links) != 1 || result.Links[0] != server.URL+"/docs/sibling" {
+			t.Errorf("expected link resolved against the page, got %v", result.Links)
+		}
+	})
+}
+
+func TestCrawlSite_Redirects(t *testing.T) {
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="/elsewhere">Elsewhere</a>`)
+	}))
+	defer external.Close()
+	// A different hostname makes the server external to the crawled site
+	externalURL := strings.Replace(external.URL, "127.0.0.1", "localhost", 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/docs">Docs</a><a href="/moved">Moved</a>`)
+		case "/docs":
+			http.Redirect(w, r, "/docs/", http.StatusMovedPermanently)
+		case "/docs/":
+			fmt.Fprint(w, `<a href="intro">Intro</a>`)
+		case "/moved":
+			http.Redirect(w, r, externalURL+"/", http.StatusFound)
+		default:
+			fmt.Fprint(w, `<html></html>`)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	result := CrawlSite(server.URL+"/", CrawlOptions{MaxDepth: 2, Workers: 2, IgnoreRobots: true})
+	// Relative links resolve against the page the redirect ended at, and
+	// a page that redirects to another site is not read
+	expected := []string{"/docs", "/moved", "/docs/intro"}
+	if len(result.Links) != len(expected) {
+		t.Fatalf("expected links %v, got %v", expected, result.Links)
+	}
+	for i := range expected {
+		if result.Links[i] != server.URL+expected[i] {
+			t.Errorf("expected link %d to be %q, got %q", i, server.URL+expected[i], result.Links[i])
+		}
+	}
+	if page := result.Sources[server.URL+"/docs/intro"][0].Page; page != server.URL+"/docs" {
+		t.Errorf("expected the source page to be the linked url, got %q", page)
+	}
 }
 
 // Benchmark tests
@@ -252,12 +745,12 @@ func BenchmarkResolveURL(b *testing.B) {
 	}
 }
 
-func BenchmarkIsInternalLink(b *testing.B) {
+func BenchmarkScope_Internal(b *testing.B) {
 	link := "https://example.com/about"
-	baseURL := "https://example.com"
+	scope, _ := NewScope("https://example.com", ScopeOptions{})
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		isInternalLink(link, baseURL)
+		scope.Internal(link)
 	}
 }
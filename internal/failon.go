@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FailRule decides whether a set of dead links should fail the run.
+// Rules are written as a selector with an optional threshold:
+//
+//	any            any dead link
+//	internal       any dead link on the crawled site
+//	external>5     more than 5 dead links to other sites
+//	4xx, 503       any dead link with a matching status
+//	timeout        any dead link in an error category
+//	none           never fail because of dead links
+type FailRule struct {
+	Expr      string // the rule as written
+	selector  string
+	threshold int // fail when more than this many links match
+}
+
+// statusPattern matches a status code or class such as 404 or 5xx
+var statusPattern = regexp.MustCompile(`^[1-5]([0-9]{2}|xx)$`)
+
+// failCategories are the error categories a rule can select
+var failCategories = map[string]bool{
+	string(CategoryInvalid):  true,
+	string(CategoryDNS):      true,
+	string(CategoryTimeout):  true,
+	string(CategoryTLS):      true,
+	string(CategoryRefused):  true,
+	string(CategoryNetwork):  true,
+	string(CategoryRedirect): true,
+	string(CategoryAnchor):   true,
+	string(CategoryFile):     true,
+	string(CategoryHTTP):     true,
+}
+
+// ParseFailRule parses a single --fail-on expression
+func ParseFailRule(expr string) (FailRule, error) {
+	rule := FailRule{Expr: expr}
+	selector, threshold, hasThreshold := strings.Cut(strings.ToLower(strings.TrimSpace(expr)), ">")
+	rule.selector = strings.TrimSpace(selector)
+
+	if hasThreshold {
+		n, err := strconv.Atoi(strings.TrimSpace(threshold))
+		if err != nil || n < 0 {
+			return rule, fmt.Errorf("invalid threshold in fail rule %q", expr)
+		}
+		rule.threshold = n
+	}
+
+	switch {
+	case rule.selector == "none":
+		if hasThreshold {
+			return rule, fmt.Errorf("fail rule %q cannot take a threshold", expr)
+		}
+	case rule.selector == "any", rule.selector == "internal", rule.selector == "external":
+	case statusPattern.MatchString(rule.selector):
+	case failCategories[rule.selector]:
+	default:
+		return rule, fmt.Errorf("unknown fail rule %q", expr)
+	}
+	return rule, nil
+}
+
+// matches reports whether a dead result is selected by the rule
+func (r FailRule) matches(result LinkResult, scope *Scope) bool {
+	switch {
+	case r.selector == "any":
+		return true
+	case r.selector == "internal":
+		return scope.Internal(result.URL)
+	case r.selector == "external":
+		return !scope.Internal(result.URL)
+	case statusPattern.MatchString(r.selector):
+		return matchesStatus(r.selector, result.StatusCode)
+	default:
+		return string(result.Category) == r.selector
+	}
+}
+
+// matchesStatus reports whether a status code matches a code such as 404
+// or a class such as 5xx
+func matchesStatus(selector string, statusCode int) bool {
+	code := strconv.Itoa(statusCode)
+	if strings.HasSuffix(selector, "xx") {
+		return statusCode != 0 && code[0] == selector[0]
+	}
+	return code == selector
+}
+
+// Count returns how many dead results the rule selects
+func (r FailRule) Count(dead []LinkResult, scope *Scope) int {
+	count := 0
+	for _, result := range dead {
+		if result.Dead && r.matches(result, scope) {
+			count++
+		}
+	}
+	return count
+}
+
+// Triggered reports whether the dead results exceed the rule's threshold
+func (r FailRule) Triggered(dead []LinkResult, scope *Scope) bool {
+	if r.selector == "none" {
+		return false
+	}
+	return r.Count(dead, scope) > r.threshold
+}
+
+// FailPolicy is a set of rules; the run fails if any one of them triggers
+type FailPolicy []FailRule
+
+// ParseFailPolicy parses every --fail-on expression
+func ParseFailPolicy(exprs []string) (FailPolicy, error) {
+	var policy FailPolicy
+	for _, expr := range exprs {
+		rule, err := ParseFailRule(expr)
+		if err != nil {
+			return nil, err
+		}
+		policy = append(policy, rule)
+	}
+	return policy, nil
+}
+
+// Triggered returns the rules that the dead results trigger
+func (p FailPolicy) Triggered(dead []LinkResult, scope *Scope) []FailRule {
+	var triggered []FailRule
+	for _, rule := range p {
+		if rule.Triggered(dead, scope) {
+			triggered = append(triggered, rule)
+		}
+	}
+	return triggered
+}
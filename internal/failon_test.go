@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestParseFailRule(t *testing.T) {
+	valid := []string{"any", "internal", "external>5", " External > 0 ", "4xx", "5xx", "404", "timeout", "dns", "none", "any>0"}
+	for _, expr := range valid {
+		if _, err := ParseFailRule(expr); err != nil {
+			t.Errorf("ParseFailRule(%q) unexpected error: %v", expr, err)
+		}
+	}
+
+	invalid := []string{"", "everything", "external>", "external>-1", "external>many", "6xx", "40x", "none>3"}
+	for _, expr := range invalid {
+		if _, err := ParseFailRule(expr); err == nil {
+			t.Errorf("ParseFailRule(%q) expected an error", expr)
+		}
+	}
+}
+
+func TestFailRuleTriggered(t *testing.T) {
+	scope, _ := NewScope("https://example.com/", ScopeOptions{})
+	dead := []LinkResult{
+		{URL: "https://example.com/missing", StatusCode: 404, Dead: true, Category: CategoryHTTP},
+		{URL: "https://other.com/a", StatusCode: 503, Dead: true, Category: CategoryHTTP},
+		{URL: "https://other.com/b", Dead: true, Category: CategoryTimeout},
+		{URL: "https://third.com/c", StatusCode: 410, Dead: true, Category: CategoryHTTP},
+	}
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"any", true},
+		{"any>4", false},
+		{"internal", true},
+		{"internal>1", false},
+		{"external>2", true},
+		{"external>3", false},
+		{"4xx", true},
+		{"4xx>2", false},
+		{"5xx", true},
+		{"404", true},
+		{"500", false},
+		{"timeout", true},
+		{"dns", false},
+		{"none", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			rule, err := ParseFailRule(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFailRule(%q) error = %v", tt.expr, err)
+			}
+			if got := rule.Triggered(dead, scope); got != tt.expected {
+				t.Errorf("%q triggered = %v, expected %v (count %d)", tt.expr, got, tt.expected, rule.Count(dead, scope))
+			}
+		})
+	}
+
+	// Nothing dead never triggers
+	rule, _ := ParseFailRule("any")
+	if rule.Triggered(nil, scope) {
+		t.Errorf("expected no trigger without dead links")
+	}
+}
+
+func TestFailPolicy(t *testing.T) {
+	if _, err := ParseFailPolicy([]string{"internal", "bogus"}); err == nil {
+		t.Errorf("expected an error for an invalid rule")
+	}
+
+	policy, err := ParseFailPolicy([]string{"internal", "external>1", "5xx"})
+	if err != nil {
+		t.Fatalf("ParseFailPolicy() error = %v", err)
+	}
+	dead := []LinkResult{
+		{URL: "https://other.com/a", StatusCode: 502, Dead: true, Category: CategoryHTTP},
+	}
+	scope, _ := NewScope("https://example.com/", ScopeOptions{})
+	triggered := policy.Triggered(dead, scope)
+	if len(triggered) != 1 || triggered[0].Expr != "5xx" {
+		t.Errorf("expected only the 5xx rule to trigger, got %+v", triggered)
+	}
+
+	// Hosts added to the scope count as internal
+	scope, _ = NewScope("https://example.com/", ScopeOptions{ExtraHosts: []string{"other.com"}})
+	triggered = policy.Triggered(dead, scope)
+	if len(triggered) != 2 || triggered[0].Expr != "internal" {
+		t.Errorf("expected the internal and 5xx rules to trigger, got %+v", triggered)
+	}
+}
@@ -0,0 +1,286 @@
+package internal
+
+import (
+	"fmt"
+	"io/fs"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// fileParsers picks the parser for a file by its extension. Directories
+// and glob patterns only match files with one of these extensions.
+var fileParsers = map[string]func(string) (*Page, error){
+	".md":       parseMarkdownPage,
+	".markdown": parseMarkdownPage,
+	".html":     ParsePage,
+	".htm":      ParsePage,
+}
+
+func parseMarkdownPage(content string) (*Page, error) {
+	return ParseMarkdown(content), nil
+}
+
+// parseFile parses a file as HTML or, for any other extension, Markdown
+func parseFile(file string) (*Page, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	parse, ok := fileParsers[strings.ToLower(filepath.Ext(file))]
+	if !ok {
+		parse = parseMarkdownPage
+	}
+	return parse(string(content))
+}
+
+// FindFiles expands paths into the files to check. A file is taken as it
+// is, a directory is searched for Markdown and HTML files, skipping hidden
+// directories and node_modules, and a pattern holding *, ? or [ matches
+// paths where ** spans any number of directories. Each file appears once,
+// in the order found.
+func FindFiles(paths []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(file string) {
+		if abs, err := filepath.Abs(file); err == nil && !seen[abs] {
+			seen[abs] = true
+			files = append(files, file)
+		}
+	}
+
+	for _, p := range paths {
+		if strings.ContainsAny(p, "*?[") {
+			matches, err := globFiles(p)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", p)
+			}
+			for _, match := range matches {
+				add(match)
+			}
+			continue
+		}
+
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			add(p)
+			continue
+		}
+		err = walkFiles(p, func(file string) {
+			add(file)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// walkFiles calls found for every Markdown and HTML file under dir
+func walkFiles(dir string, found func(string)) error {
+	return filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if name := entry.Name(); file != dir && (strings.HasPrefix(name, ".") || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, ok := fileParsers[strings.ToLower(filepath.Ext(file))]; ok {
+			found(file)
+		}
+		return nil
+	})
+}
+
+// globFiles returns the Markdown and HTML files matching a pattern. The
+// directories before the first wildcard are walked and every path below
+// them is matched one segment at a time.
+func globFiles(pattern string) ([]string, error) {
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	static := 0
+	for static < len(segments) && !strings.ContainsAny(segments[static], "*?[") {
+		static++
+	}
+	dir := strings.Join(segments[:static], "/")
+	if dir == "" && strings.HasPrefix(pattern, "/") {
+		dir = "/"
+	} else if dir == "" {
+		dir = "."
+	}
+	for _, segment := range segments[static:] {
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+
+	var matches []string
+	err := walkFiles(filepath.FromSlash(dir), func(file string) {
+		rel, err := filepath.Rel(filepath.FromSlash(dir), file)
+		if err == nil && matchSegments(segments[static:], strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, file)
+		}
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// ** segment matches any number of path segments
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for skip := 0; skip <= len(segments); skip++ {
+			if matchSegments(pattern[1:], segments[skip:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], segments[0])
+	return ok && matchSegments(pattern[1:], segments[1:])
+}
+
+// FileOptions controls how links are collected from files
+type FileOptions struct {
+	Root       string      // directory that links starting with / resolve against
+	Kinds      []string    // link kinds to check, empty for all
+	Normalizer *Normalizer // canonicalizes urls; nil only strips fragments
+}
+
+// fileURL returns the file:// url of a path
+func fileURL(file string) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	return (&neturl.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String(), nil
+}
+
+// CollectFileLinks parses every file and gathers its links as a crawl of
+// those pages would. Relative links resolve against the file they are in
+// and links starting with / against the root, becoming file:// urls that
+// NewFileTree checks on disk; the sources of each link name the file as
+// given. Anchors hold the headings and ids of every file read, including
+// Markdown and HTML files that links with a fragment point at.
+func CollectFileLinks(files []string, opts FileOptions) (*CrawlResult, error) {
+	result := newCrawlResult()
+	root := opts.Root
+	if root == "" {
+		root = "."
+	}
+	rootURL, err := fileURL(root)
+	if err != nil {
+		return nil, err
+	}
+	rootURL = strings.TrimSuffix(rootURL, "/") + "/"
+	var kinds map[string]bool
+	if len(opts.Kinds) > 0 {
+		kinds = make(map[string]bool)
+		for _, kind := range opts.Kinds {
+			kinds[kind] = true
+		}
+	}
+
+	for _, file := range files {
+		page, err := parseFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		pageURL, err := fileURL(file)
+		if err != nil {
+			return nil, err
+		}
+		result.Anchors.add(pageURL, page.Anchors)
+
+		for _, link := range page.Links {
+			if kinds != nil && !kinds[link.Kind] {
+				continue
+			}
+			var absoluteURL string
+			switch {
+			case strings.HasPrefix(link.URL, "//"):
+				absoluteURL = resolveURL(link.URL, "https:")
+			case strings.HasPrefix(link.URL, "/"):
+				absoluteURL = resolveURL("."+link.URL, rootURL)
+			default:
+				absoluteURL = resolveURL(link.URL, baseURL(pageURL, page.BaseHref))
+			}
+			if absoluteURL == "" {
+				continue
+			}
+			written := absoluteURL
+			absoluteURL = opts.Normalizer.Normalize(written)
+
+			if _, seen := result.Sources[absoluteURL]; !seen {
+				result.Links = append(result.Links, absoluteURL)
+				spell(result.Spellings, absoluteURL, written)
+			}
+			result.Sources[absoluteURL] = append(result.Sources[absoluteURL], LinkSource{
+				Page:      file,
+				Original:  link.URL,
+				Element:   link.Element,
+				Attribute: link.Attribute,
+				Kind:      link.Kind,
+				Text:      link.Text,
+				Line:      link.Line,
+				Column:    link.Column,
+			})
+		}
+	}
+
+	// Fragments into files that were not read need their anchors too
+	for _, link := range result.Links {
+		target, fragment, _ := strings.Cut(link, "#")
+		if fragment == "" || !strings.HasPrefix(target, "file:") {
+			continue
+		}
+		if _, read := result.Anchors[target]; read {
+			continue
+		}
+		u, err := neturl.Parse(target)
+		if err != nil {
+			continue
+		}
+		if _, ok := fileParsers[strings.ToLower(path.Ext(u.Path))]; !ok {
+			continue
+		}
+		if page, err := parseFile(filepath.FromSlash(u.Path)); err == nil {
+			result.Anchors.add(target, page.Anchors)
+		}
+	}
+	return result, nil
+}
+
+// NewFileTree returns a LocalSite serving every file:// url from disk, as
+// a repository browser does: a link to a directory is valid without an
+// index.html. Messages name files relative to the working directory.
+func NewFileTree() *LocalSite {
+	display, err := os.Getwd()
+	if err != nil {
+		display = string(filepath.Separator)
+	}
+	return &LocalSite{
+		root:    string(filepath.Separator),
+		base:    &neturl.URL{Scheme: "file", Path: "/"},
+		dirs:    true,
+		display: display,
+	}
+}
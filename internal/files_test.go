@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindFiles(t *testing.T) {
+	root := writeSite(t, map[string]string{
+		"README.md":               "",
+		"docs/guide.md":           "",
+		"docs/api/index.markdown": "",
+		"docs/page.html":          "",
+		"docs/notes.txt":          "",
+		"docs/.hidden/secret.md":  "",
+		"node_modules/lib/x.md":   "",
+	})
+	t.Chdir(root)
+
+	tests := []struct {
+		paths    []string
+		expected string
+	}{
+		{[]string{"."}, "README.md docs/api/index.markdown docs/guide.md docs/page.html"},
+		{[]string{"docs", "README.md", "docs/guide.md"}, "docs/api/index.markdown docs/guide.md docs/page.html README.md"},
+		{[]string{"docs/*.md"}, "docs/guide.md"},
+		{[]string{"**/*.md"}, "README.md docs/guide.md"},
+		{[]string{"docs/**/index.*"}, "docs/api/index.markdown"},
+		{[]string{"docs/notes.txt"}, "docs/notes.txt"},
+	}
+	for _, tt := range tests {
+		files, err := FindFiles(tt.paths)
+		if err != nil {
+			t.Errorf("FindFiles(%v) error = %v", tt.paths, err)
+			continue
+		}
+		for i := range files {
+			files[i] = filepath.ToSlash(files[i])
+		}
+		if got := strings.Join(files, " "); got != tt.expected {
+			t.Errorf("FindFiles(%v) = %q, expected %q", tt.paths, got, tt.expected)
+		}
+	}
+
+	for _, paths := range [][]string{{"missing.md"}, {"*.rst"}, {"docs/[.md"}} {
+		if _, err := FindFiles(paths); err == nil {
+			t.Errorf("FindFiles(%v) expected an error", paths)
+		}
+	}
+}
+
+func TestCollectFileLinks(t *testing.T) {
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ok" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer external.Close()
+
+	root := writeSite(t, map[string]string{
+		"README.md": fmt.Sprintf(`# Project
+
+- [Guide](docs/guide.md#install)
+- [Missing section](docs/guide.md#uninstall)
+- [Reference](docs/reference.md#usage)
+- [Docs folder](docs/)
+- [Gone](docs/gone.md)
+- [Top](#project)
+- [Nowhere](#nowhere)
+- ![Logo](/assets/logo.png)
+- [Partner](%[1]s/ok) and [broken partner](%[1]s/broken)
+`, external.URL),
+		"docs/guide.md":     "# Guide\n\n## Install\n\nBack to the [readme](../README.md).\n",
+		"docs/reference.md": "<h2 id=\"usage\">Usage</h2>\n",
+		"assets/logo.png":   "png",
+	})
+	t.Chdir(root)
+
+	crawl, err := CollectFileLinks([]string{"README.md", "docs/guide.md"}, FileOptions{Normalizer: NewNormalizer(DefaultNormalizeOptions())})
+	if err != nil {
+		t.Fatalf("CollectFileLinks() error = %v", err)
+	}
+	logo := "file://" + filepath.ToSlash(filepath.Join(root, "assets/logo.png"))
+	if sources := crawl.Sources[logo]; len(sources) != 1 || sources[0].Page != "README.md" || sources[0].Line != 10 || sources[0].Original != "/assets/logo.png" {
+		t.Errorf("expected the logo to resolve against the root, got %+v", crawl.Sources[logo])
+	}
+
+	opts := DefaultCheckOptions()
+	opts.Anchors = crawl.Anchors
+	opts.Local = NewFileTree()
+	results := CheckLinks(&crawl.Links, opts)
+
+	dead := make(map[string]LinkResult)
+	for _, result := range DeadResults(results) {
+		dead[crawl.Sources[result.URL][0].Original] = result
+	}
+	expected := map[string]ErrorCategory{
+		"docs/guide.md#uninstall": CategoryAnchor,
+		"docs/gone.md":            CategoryFile,
+		"#nowhere":                CategoryAnchor,
+		external.URL + "/broken":  CategoryHTTP,
+	}
+	for original, category := range expected {
+		if result, ok := dead[original]; !ok || result.Category != category {
+			t.Errorf("expected %s dead with category %s, got %+v", original, category, result)
+		}
+	}
+	if len(dead) != len(expected) {
+		t.Errorf("expected %d dead links, got %+v", len(expected), dead)
+	}
+	if gone := dead["docs/gone.md"]; gone.Error != "docs/gone.md not found" {
+		t.Errorf("expected the missing file relative to the working directory, got %q", gone.Error)
+	}
+
+	// Local files are internal to a file scope
+	scope, _ := NewScope("file:///", ScopeOptions{})
+	if !scope.Internal(dead["docs/gone.md"].URL) || scope.Internal(external.URL) {
+		t.Errorf("expected only file urls to be internal")
+	}
+
+	if _, err := CollectFileLinks([]string{"missing.md"}, FileOptions{}); err == nil {
+		t.Errorf("expected an error for a file that cannot be read")
+	}
+}
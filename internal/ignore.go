@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	neturl "net/url"
+	"regexp"
+	"strings"
+)
+
+// IgnoreRule silences links matching a pattern. Patterns use the same
+// syntax as the crawl scope. Without statuses the link is never requested;
+// with statuses it is checked, and only failures with one of them are
+// silenced. Rules are written as a pattern followed by optional statuses:
+//
+//	https://www.linkedin.com/*        never checked
+//	*twitter.com/* 403 429            checked, but 403 and 429 are ignored
+//	* 999                             a 999 is ignored on any link
+//	/legacy/* 4xx timeout             status classes and error categories work too
+type IgnoreRule struct {
+	Expr     string // the rule as written
+	pattern  urlPattern
+	statuses []string // status codes, classes and error categories, empty to skip the link
+}
+
+// ignoreStatusPattern matches a status code or class such as 999 or 4xx.
+// Unlike --fail-on, non-standard codes used by bot blockers are accepted.
+var ignoreStatusPattern = regexp.MustCompile(`^[1-9]([0-9]{2}|xx)$`)
+
+// ParseIgnoreRule parses a single "pattern [status...]" rule
+func ParseIgnoreRule(expr string) (IgnoreRule, error) {
+	rule := IgnoreRule{Expr: strings.TrimSpace(expr)}
+	fields := strings.Fields(expr)
+	if len(fields) == 0 {
+		return rule, fmt.Errorf("empty ignore rule")
+	}
+	pattern, err := compilePattern(fields[0])
+	if err != nil {
+		return rule, err
+	}
+	rule.pattern = pattern
+
+	for _, status := range fields[1:] {
+		status = strings.ToLower(status)
+		if !ignoreStatusPattern.MatchString(status) && !failCategories[status] {
+			return rule, fmt.Errorf("invalid status %q in ignore rule %q", status, rule.Expr)
+		}
+		rule.statuses = append(rule.statuses, status)
+	}
+	return rule, nil
+}
+
+// matchesURL reports whether the rule's pattern matches rawURL
+func (r IgnoreRule) matchesURL(rawURL string) bool {
+	u, err := neturl.Parse(rawURL)
+	return err == nil && r.pattern.match(u)
+}
+
+// matchesResult reports whether a dead result has one of the rule's statuses
+func (r IgnoreRule) matchesResult(result LinkResult) bool {
+	for _, status := range r.statuses {
+		if ignoreStatusPattern.MatchString(status) {
+			if matchesStatus(status, result.StatusCode) {
+				return true
+			}
+		} else if string(result.Category) == status {
+			return true
+		}
+	}
+	return false
+}
+
+// IgnoreList is a set of rules; a link is ignored if any one of them matches
+type IgnoreList []IgnoreRule
+
+// ParseIgnoreList parses every --ignore rule
+func ParseIgnoreList(exprs []string) (IgnoreList, error) {
+	var list IgnoreList
+	for _, expr := range exprs {
+		rule, err := ParseIgnoreRule(expr)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, rule)
+	}
+	return list, nil
+}
+
+// ReadIgnoreFile reads one rule per line. Blank lines and lines starting
+// with # are skipped.
+func ReadIgnoreFile(r io.Reader) (IgnoreList, error) {
+	var list IgnoreList
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := ParseIgnoreRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("ignore file line %d: %w", lineNumber, err)
+		}
+		list = append(list, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading ignore file: %w", err)
+	}
+	return list, nil
+}
+
+// Skip reports whether rawURL should not be requested at all
+func (l IgnoreList) Skip(rawURL string) bool {
+	for _, rule := range l {
+		if len(rule.statuses) == 0 && rule.matchesURL(rawURL) {
+			return true
+		}
+	}
+	return false
+}
+
+// suppress marks a dead result ignored when a rule silences its status.
+// The status and error are kept so reports can say what was ignored.
+func (l IgnoreList) suppress(result *LinkResult) {
+	if !result.Dead {
+		return
+	}
+	for _, rule := range l {
+		if len(rule.statuses) > 0 && rule.matchesResult(*result) && rule.matchesURL(result.URL) {
+			result.Dead = false
+			result.Ignored = true
+			return
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseIgnoreRule(t *testing.T) {
+	valid := []string{"https://www.linkedin.com/*", "* 999", "/legacy/* 4xx timeout", "re:twitter\\.com 403 429", "  *.pdf  "}
+	for _, expr := range valid {
+		if _, err := ParseIgnoreRule(expr); err != nil {
+			t.Errorf("ParseIgnoreRule(%q) error = %v", expr, err)
+		}
+	}
+
+	invalid := []string{"", "   ", "* 40", "* 4x", "* unknown", "re:( 404"}
+	for _, expr := range invalid {
+		if _, err := ParseIgnoreRule(expr); err == nil {
+			t.Errorf("ParseIgnoreRule(%q) expected an error", expr)
+		}
+	}
+}
+
+func TestReadIgnoreFile(t *testing.T) {
+	file := strings.Join([]string{
+		"# Sites that block bots",
+		"https://www.linkedin.com/*",
+		"",
+		"*twitter.com/* 403",
+	}, "\n")
+	list, err := ReadIgnoreFile(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("ReadIgnoreFile() error = %v", err)
+	}
+	if len(list) != 2 || list[1].Expr != "*twitter.com/* 403" {
+		t.Errorf("unexpected rules %+v", list)
+	}
+
+	_, err = ReadIgnoreFile(strings.NewReader("ok\n* bogus\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error naming line 2, got %v", err)
+	}
+}
+
+func TestIgnoreList(t *testing.T) {
+	list, err := ParseIgnoreList([]string{"https://www.linkedin.com/*", "* 999", "/legacy/* 4xx timeout"})
+	if err != nil {
+		t.Fatalf("ParseIgnoreList() error = %v", err)
+	}
+
+	if !list.Skip("https://www.linkedin.com/in/someone") {
+		t.Errorf("expected the linkedin link to be skipped")
+	}
+	// Rules with statuses never skip the request
+	if list.Skip("https://example.com/legacy/a") {
+		t.Errorf("expected a rule with statuses not to skip the request")
+	}
+
+	tests := []struct {
+		result  LinkResult
+		ignored bool
+	}{
+		{LinkResult{URL: "https://other.com/", StatusCode: 999, Dead: true, Category: CategoryHTTP}, true},
+		{LinkResult{URL: "https://other.com/", StatusCode: 404, Dead: true, Category: CategoryHTTP}, false},
+		{LinkResult{URL: "https://example.com/legacy/a", StatusCode: 410, Dead: true, Category: CategoryHTTP}, true},
+		{LinkResult{URL: "https://example.com/legacy/a", Dead: true, Category: CategoryTimeout}, true},
+		{LinkResult{URL: "https://example.com/legacy/a", StatusCode: 500, Dead: true, Category: CategoryHTTP}, false},
+		{LinkResult{URL: "https://example.com/current", StatusCode: 410, Dead: true, Category: CategoryHTTP}, false},
+	}
+	for _, tt := range tests {
+		result := tt.result
+		list.suppress(&result)
+		if result.Ignored != tt.ignored || result.Dead == tt.ignored {
+			t.Errorf("suppress(%+v) = %+v, expected ignored %v", tt.result, result, tt.ignored)
+		}
+		if result.StatusCode != tt.result.StatusCode || result.Category != tt.result.Category {
+			t.Errorf("expected the failure to be kept, got %+v", result)
+		}
+	}
+}
+
+func TestCheckLinks_Ignore(t *testing.T) {
+	var mu sync.Mutex
+	requested := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.URL.Path] = true
+		mu.Unlock()
+		if r.URL.Path == "/blocked" {
+			w.WriteHeader(999)
+		}
+	}))
+	defer server.Close()
+
+	list, _ := ParseIgnoreList([]string{"/skipped/*", "* 999"})
+	urls := []string{server.URL + "/skipped/page", server.URL + "/blocked", server.URL + "/ok"}
+	var reported []string
+	results := CheckLinks(&urls, CheckOptions{Concurrency: 2, PerHost: 2, Ignore: list, OnResult: func(result LinkResult) {
+		reported = append(reported, result.URL)
+	}})
+
+	if requested["/skipped/page"] {
+		t.Errorf("expected the skipped link not to be requested")
+	}
+	if !results[0].Ignored || results[0].Dead || results[0].StatusCode != 0 {
+		t.Errorf("expected the skipped link to be ignored, got %+v", results[0])
+	}
+	if !results[1].Ignored || results[1].Dead || results[1].StatusCode != 999 {
+		t.Errorf("expected the 999 to be ignored, got %+v", results[1])
+	}
+	if results[2].Ignored || results[2].Dead {
+		t.Errorf("expected the plain link to be alive, got %+v", results[2])
+	}
+	if len(reported) != 3 {
+		t.Errorf("expected OnResult for every link, got %v", reported)
+	}
+
+	summary := Summarize(newCrawlResult(), results)
+	if summary.Ignored != 2 || summary.Alive != 1 || summary.Dead != 0 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if NewReportLink(results[1], nil, false).Status != "ignored" {
+		t.Errorf("expected the report status to be ignored")
+	}
+}
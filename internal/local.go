@@ -0,0 +1,199 @@
+package internal
+
+import (
+	"fmt"
+	"io/fs"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultLocalBaseURL is where a local site is assumed to be served when
+// no base url is given
+const DefaultLocalBaseURL = "http://localhost/"
+
+// localMethod is the Method of results found on disk rather than requested
+const localMethod = "FILE"
+
+// LocalSite is a static site build on disk, such as the public/ directory
+// of a Hugo site. Urls under the base url map to files under the root the
+// way a static file server maps them, with a directory served by its
+// index.html; links to those urls are checked on disk instead of over HTTP.
+type LocalSite struct {
+	root    string      // absolute directory holding the build
+	base    *neturl.URL // where the root is served, its path ending in /
+	dirs    bool        // a directory is a valid target by itself, as in a repository
+	display string      // directory that paths in messages are relative to
+}
+
+// NewLocalSite returns the site built into the directory root and served
+// at baseURL. It fails when root is not a directory or baseURL is not an
+// absolute http or https url.
+func NewLocalSite(root, baseURL string) (*LocalSite, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+
+	base, err := neturl.Parse(baseURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("base URL %q must be an absolute http or https URL", baseURL)
+	}
+	base = &neturl.URL{Scheme: base.Scheme, Host: base.Host, Path: base.Path}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	return &LocalSite{root: root, base: base, display: filepath.Dir(root)}, nil
+}
+
+// Root returns the directory holding the site
+func (s *LocalSite) Root() string {
+	return s.root
+}
+
+// BaseURL returns the url the root directory is served at
+func (s *LocalSite) BaseURL() string {
+	return s.base.String()
+}
+
+// pageURL returns the url a file is served at, given its slash separated
+// path under the root. An index.html is served at its directory's url.
+func (s *LocalSite) pageURL(rel string) string {
+	if dir, file := path.Split(rel); file == "index.html" {
+		rel = dir
+	}
+	return s.base.ResolveReference(&neturl.URL{Path: rel}).String()
+}
+
+// File returns the path on disk that serves rawURL, which need not exist,
+// and whether rawURL is under the base url at all. A url ending in / is
+// served by the index.html of its directory.
+func (s *LocalSite) File(rawURL string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	u, err := neturl.Parse(rawURL)
+	if err != nil || !strings.EqualFold(u.Scheme, s.base.Scheme) || !strings.EqualFold(u.Host, s.base.Host) {
+		return "", false
+	}
+	// The base path without its slash is the root directory too
+	urlPath := u.Path
+	if urlPath+"/" == s.base.Path {
+		urlPath = s.base.Path
+	}
+	rel, ok := strings.CutPrefix(urlPath, s.base.Path)
+	if !ok {
+		return "", false
+	}
+	// Cleaning a rooted path keeps .. from climbing out of the root
+	file := filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+rel)))
+	if strings.HasSuffix(urlPath, "/") && !s.dirs {
+		file = filepath.Join(file, "index.html")
+	}
+	return file, true
+}
+
+// Contains reports whether rawURL is served from the local site
+func (s *LocalSite) Contains(rawURL string) bool {
+	_, ok := s.File(rawURL)
+	return ok
+}
+
+// check looks for the file serving rawURL. It is dead with CategoryFile
+// when there is none.
+func (s *LocalSite) check(rawURL string) LinkResult {
+	result := LinkResult{URL: rawURL, FinalURL: rawURL, Method: localMethod, Attempts: 1}
+	file, _ := s.File(rawURL)
+	// Without its slash a directory url still reaches the index page
+	info, err := os.Stat(file)
+	if err == nil && info.IsDir() && !s.dirs {
+		file = filepath.Join(file, "index.html")
+		info, err = os.Stat(file)
+	}
+	if err != nil || (info.IsDir() && !s.dirs) {
+		result.Dead = true
+		result.Category = CategoryFile
+		result.Error = fmt.Sprintf("%s not found", s.relative(file))
+	}
+	return result
+}
+
+// relative returns the path of a file for messages. For a build it starts
+// with the root's own name, so it reads like the build directory.
+func (s *LocalSite) relative(file string) string {
+	rel, err := filepath.Rel(s.display, file)
+	if err != nil {
+		return file
+	}
+	return filepath.ToSlash(rel)
+}
+
+// CrawlLocal reads every HTML file of a local site instead of crawling it
+// over HTTP. Pages are named by the url they are served at, so links
+// resolve as they would on the live site; robots.txt, sitemaps and the
+// crawl depth do not apply, while the scope still decides which pages
+// are read.
+func CrawlLocal(site *LocalSite, opts CrawlOptions) *CrawlResult {
+	opts.IgnoreRobots = true
+	c := newCrawler(site.BaseURL(), opts)
+
+	var files []string
+	err := filepath.WalkDir(site.root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ext := strings.ToLower(filepath.Ext(file)); !entry.IsDir() && (ext == ".html" || ext == ".htm") {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		c.result.StartError = err.Error()
+		return c.result
+	}
+	if len(files) == 0 {
+		c.result.StartError = "no HTML files in " + site.root
+		return c.result
+	}
+
+	for _, file := range files {
+		rel, err := filepath.Rel(site.root, file)
+		if err != nil {
+			continue
+		}
+		pageURL := opts.Normalizer.PageURL(site.pageURL(filepath.ToSlash(rel)))
+		if !c.scope.Crawlable(pageURL) {
+			continue
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		page, err := ParsePage(string(content))
+		if err != nil {
+			continue
+		}
+
+		c.result.Anchors.add(pageURL, page.Anchors)
+		// Links may also name an index page by its file or leave off the slash
+		if filepath.Base(file) == "index.html" {
+			indexURL := site.base.ResolveReference(&neturl.URL{Path: filepath.ToSlash(rel)}).String()
+			c.result.Anchors.add(opts.Normalizer.PageURL(indexURL), page.Anchors)
+			if dirURL := strings.TrimSuffix(indexURL, "/index.html"); dirURL != indexURL {
+				c.result.Anchors.add(opts.Normalizer.PageURL(dirURL), page.Anchors)
+			}
+		}
+		found, _ := c.merge(pageURL, baseURL(pageURL, page.BaseHref), page.Links)
+		c.admit(found, nil)
+	}
+	return c.result
+}
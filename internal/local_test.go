@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSite creates files under a temporary directory named public
+func writeSite(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "public")
+	for name, content := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestLocalSite_File(t *testing.T) {
+	root := writeSite(t, map[string]string{"index.html": ""})
+	site, err := NewLocalSite(root, "https://example.com/docs")
+	if err != nil {
+		t.Fatalf("NewLocalSite() error = %v", err)
+	}
+	if site.BaseURL() != "https://example.com/docs/" {
+		t.Errorf("BaseURL() = %q", site.BaseURL())
+	}
+
+	tests := []struct {
+		url  string
+		file string // relative to root, "-" when not on the local site
+	}{
+		{"https://example.com/docs/", "index.html"},
+		{"https://example.com/docs", "index.html"},
+		{"https://example.com/docs/guide/", "guide/index.html"},
+		{"https://example.com/docs/guide", "guide"},
+		{"https://EXAMPLE.com/docs/a%20b.html?x=1#top", "a b.html"},
+		{"https://example.com/docs/../../etc/passwd", "etc/passwd"},
+		{"https://example.com/blog/", "-"},
+		{"https://example.com/docsearch", "-"},
+		{"http://example.com/docs/", "-"},
+		{"https://other.com/docs/", "-"},
+	}
+	for _, tt := range tests {
+		file, ok := site.File(tt.url)
+		if tt.file == "-" {
+			if ok {
+				t.Errorf("File(%q) = %q, expected it to be off the local site", tt.url, file)
+			}
+			continue
+		}
+		if expected := filepath.Join(root, filepath.FromSlash(tt.file)); !ok || file != expected {
+			t.Errorf("File(%q) = %q, %v, expected %q", tt.url, file, ok, expected)
+		}
+	}
+
+	invalid := [][2]string{
+		{root, "/docs/"},
+		{root, "ftp://example.com/"},
+		{filepath.Join(root, "index.html"), DefaultLocalBaseURL},
+		{filepath.Join(root, "missing"), DefaultLocalBaseURL},
+	}
+	for _, args := range invalid {
+		if _, err := NewLocalSite(args[0], args[1]); err == nil {
+			t.Errorf("NewLocalSite(%q, %q) expected an error", args[0], args[1])
+		}
+	}
+}
+
+func TestCrawlLocal(t *testing.T) {
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ok" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer external.Close()
+
+	root := writeSite(t, map[string]string{
+		"index.html": fmt.Sprintf(`<a href="/guide/">Guide</a>
+			<a href="guide/index.html#install">Install</a>
+			<a href="/guide/#missing">Missing anchor</a>
+			<a href="guide#also-missing">Missing anchor without a slash</a>
+			<a href="https://docs.example.com/about.html">About</a>
+			<a href="/gone/">Gone</a>
+			<img src="/img/logo.png">
+			<a href="%[1]s/ok">Partner</a>
+			<a href="%[1]s/broken">Broken partner</a>`, external.URL),
+		"guide/index.html":  `<h2 id="install">Install</h2><a href="../about.html">About</a><a href="setup.html">Setup</a>`,
+		"about.html":        `<a href="/">Home</a>`,
+		"img/logo.png":      "png",
+		"drafts/index.html": `<a href="/never-checked/">Draft</a>`,
+	})
+	site, err := NewLocalSite(root, "https://docs.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultCrawlOptions()
+	opts.Scope, _ = NewScope(site.BaseURL(), ScopeOptions{Exclude: []string{"/drafts/*"}})
+	crawl := CrawlLocal(site, opts)
+	if crawl.StartError != "" {
+		t.Fatalf("unexpected start error %q", crawl.StartError)
+	}
+	for _, link := range crawl.Links {
+		if strings.Contains(link, "never-checked") {
+			t.Errorf("expected excluded pages not to be read, found %s", link)
+		}
+	}
+	if sources := crawl.Sources["https://docs.example.com/guide/setup.html"]; len(sources) != 1 || sources[0].Page != "https://docs.example.com/guide/" {
+		t.Errorf("expected links to resolve against the directory url, got %+v", sources)
+	}
+
+	checkOpts := DefaultCheckOptions()
+	checkOpts.Anchors = crawl.Anchors
+	checkOpts.Local = site
+	results := CheckLinks(&crawl.Links, checkOpts)
+
+	dead := make(map[string]LinkResult)
+	for _, result := range DeadResults(results) {
+		dead[strings.TrimPrefix(result.URL, external.URL)] = result
+	}
+	expected := map[string]ErrorCategory{
+		"https://docs.example.com/guide/#missing":     CategoryAnchor,
+		"https://docs.example.com/guide#also-missing": CategoryAnchor,
+		"https://docs.example.com/gone/":              CategoryFile,
+		"https://docs.example.com/guide/setup.html":   CategoryFile,
+		"/broken": CategoryHTTP,
+	}
+	for url, category := range expected {
+		if result, ok := dead[url]; !ok || result.Category != category {
+			t.Errorf("expected %s dead with category %s, got %+v", url, category, result)
+		}
+	}
+	if len(dead) != len(expected) {
+		t.Errorf("expected %d dead links, got %+v", len(expected), dead)
+	}
+	if gone := dead["https://docs.example.com/gone/"]; gone.Error != "public/gone/index.html not found" || gone.Method != localMethod {
+		t.Errorf("unexpected local result %+v", gone)
+	}
+
+	empty := CrawlLocal(&LocalSite{root: t.TempDir(), base: site.base, display: root}, DefaultCrawlOptions())
+	if !strings.Contains(empty.StartError, "no HTML files") {
+		t.Errorf("expected a start error for an empty directory, got %q", empty.StartError)
+	}
+}
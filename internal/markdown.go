@@ -0,0 +1,599 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	// fencePattern opens or closes a fenced code block
+	fencePattern = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	// atxHeading is a heading such as "## Install"
+	atxHeading = regexp.MustCompile(`^ {0,3}#{1,6}(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	// setextUnderline turns the line above it into a heading
+	setextUnderline = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	// notSetext are lines that a --- under them does not make a heading
+	notSetext = regexp.MustCompile(`^ {0,3}([-*+>|]|\d+[.)])`)
+	// headingID is an explicit id such as "{#install}" after a heading
+	headingID = regexp.MustCompile(`[ \t]*\{#([^\s}]+)\}[ \t]*$`)
+	// referenceDefinition is a line such as "[docs]: https://example.com"
+	referenceDefinition = regexp.MustCompile(`^ {0,3}\[((?:[^\]\\]|\\.)+)\]:[ \t]*(<[^>\n]*>|\S+)`)
+	// autolink is a url in angle brackets, <https://example.com>
+	autolink = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9+.\-]{1,31}:[^\s<>]*)>`)
+	// bareURL is a url written as plain text, as GitHub links them
+	bareURL = regexp.MustCompile(`(?:https?://|www\.)[^\s<>\[\]]+`)
+	// inlineMarkup is an innermost link, image or HTML tag in heading and link text
+	inlineMarkup = regexp.MustCompile(`(!?)\[([^\[\]]*)\](?:\([^)]*\)|\[[^\]]*\])|<[^>]+>`)
+)
+
+// markdownDoc is a Markdown document being parsed. masked is a copy of the
+// source in which every construct already handled, and everything that
+// cannot hold a link such as code, is overwritten with spaces, so offsets
+// and line numbers stay those of the source.
+type markdownDoc struct {
+	source     string
+	masked     []byte
+	lineStarts []int
+	inCode     []bool   // lines inside front matter or a fenced code block
+	linkText   [][2]int // text of inline links, where a url is not a link of its own
+	links      []Link
+	anchors    map[string]bool
+}
+
+// ParseMarkdownLinks parses Markdown content and extracts all links.
+func ParseMarkdownLinks(content string) []Link {
+	return ParseMarkdown(content).Links
+}
+
+// ParseMarkdown extracts the links of a Markdown document in document
+// order: inline links and images, reference definitions, autolinks, bare
+// urls and the links of raw HTML. Code blocks, code spans and HTML
+// comments are skipped. Anchors holds the slug GitHub generates for each
+// heading, any explicit {#id} and the ids of raw HTML elements.
+func ParseMarkdown(content string) *Page {
+	d := &markdownDoc{
+		source:  content,
+		masked:  []byte(content),
+		anchors: make(map[string]bool),
+	}
+	d.lineStarts = append(d.lineStarts, 0)
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			d.lineStarts = append(d.lineStarts, i+1)
+		}
+	}
+	d.inCode = make([]bool, len(d.lineStarts))
+
+	d.maskCode()
+	d.maskComments()
+	d.findHeadings()
+	d.maskCodeSpans()
+	d.findAutolinks()
+	d.findDefinitions()
+	d.findInlineLinks()
+	d.findHTML()
+	d.findBareURLs()
+
+	sort.SliceStable(d.links, func(i, j int) bool {
+		if d.links[i].Line != d.links[j].Line {
+			return d.links[i].Line < d.links[j].Line
+		}
+		return d.links[i].Column < d.links[j].Column
+	})
+	return &Page{Links: d.links, Anchors: d.anchors}
+}
+
+// line returns the source of line i, 0-based, without its line ending
+func (d *markdownDoc) line(i int) string {
+	start, end := d.lineBounds(i)
+	return strings.TrimRight(d.source[start:end], "\r")
+}
+
+// maskedLine returns the masked bytes of line i, sharing the buffer
+func (d *markdownDoc) maskedLine(i int) []byte {
+	start, end := d.lineBounds(i)
+	return d.masked[start:end]
+}
+
+func (d *markdownDoc) lineBounds(i int) (int, int) {
+	start := d.lineStarts[i]
+	end := len(d.source)
+	if i+1 < len(d.lineStarts) {
+		end = d.lineStarts[i+1] - 1
+	}
+	return start, end
+}
+
+// position converts a byte offset into a 1-based line and column
+func (d *markdownDoc) position(offset int) (int, int) {
+	line := sort.Search(len(d.lineStarts), func(i int) bool { return d.lineStarts[i] > offset }) - 1
+	return line + 1, utf8.RuneCountInString(d.source[d.lineStarts[line]:offset]) + 1
+}
+
+// blank overwrites a span of the masked copy, keeping line breaks
+func (d *markdownDoc) blank(start, end int) {
+	for i := start; i < end; i++ {
+		if d.masked[i] != '\n' {
+			d.masked[i] = ' '
+		}
+	}
+}
+
+// add records a link found at a byte offset
+func (d *markdownDoc) add(link Link, offset int) {
+	if link.URL = cleanURL(link.URL); link.URL == "" {
+		return
+	}
+	link.Line, link.Column = d.position(offset)
+	d.links = append(d.links, link)
+}
+
+// maskCode blanks front matter and fenced code blocks
+func (d *markdownDoc) maskCode() {
+	lines := len(d.lineStarts)
+	// Front matter between --- or +++ lines at the very start
+	if first := d.line(0); first == "---" || first == "+++" {
+		for i := 1; i < lines; i++ {
+			if d.line(i) == first {
+				for j := 0; j <= i; j++ {
+					d.inCode[j] = true
+				}
+				_, end := d.lineBounds(i)
+				d.blank(0, end)
+				break
+			}
+		}
+	}
+
+	fence := ""
+	for i := 0; i < lines; i++ {
+		if d.inCode[i] && fence == "" {
+			continue
+		}
+		match := fencePattern.FindStringSubmatch(d.line(i))
+		switch {
+		case fence == "" && match != nil:
+			fence = match[1]
+		case fence != "" && match != nil && match[1][0] == fence[0] && len(match[1]) >= len(fence) &&
+			strings.TrimSpace(strings.TrimLeft(d.line(i), " "+fence[:1])) == "":
+			fence = ""
+		case fence == "":
+			continue
+		}
+		d.inCode[i] = true
+		d.blank(d.lineBounds(i))
+	}
+}
+
+// maskComments blanks HTML comments, which may span lines
+func (d *markdownDoc) maskComments() {
+	for offset := 0; ; {
+		start := bytes.Index(d.masked[offset:], []byte("<!--"))
+		if start < 0 {
+			return
+		}
+		start += offset
+		end := bytes.Index(d.masked[start+4:], []byte("-->"))
+		if end < 0 {
+			end = len(d.masked)
+		} else {
+			end += start + 4 + 3
+		}
+		d.blank(start, end)
+		offset = end
+	}
+}
+
+// maskCodeSpans blanks `code`, matching backtick runs of the same length
+func (d *markdownDoc) maskCodeSpans() {
+	for i := range d.lineStarts {
+		line := d.maskedLine(i)
+		for pos := 0; pos < len(line); {
+			if line[pos] != '`' {
+				pos++
+				continue
+			}
+			run := pos
+			for run < len(line) && line[run] == '`' {
+				run++
+			}
+			ticks := string(line[pos:run])
+			closing := -1
+			for search := run; search < len(line); {
+				next := bytes.Index(line[search:], []byte(ticks))
+				if next < 0 {
+					break
+				}
+				next += search
+				// The closing run must be exactly as long
+				after := next + len(ticks)
+				if after < len(line) && line[after] == '`' {
+					for after < len(line) && line[after] == '`' {
+						after++
+					}
+					search = after
+					continue
+				}
+				closing = after
+				break
+			}
+			if closing < 0 {
+				pos = run
+				continue
+			}
+			start, _ := d.lineBounds(i)
+			d.blank(start+pos, start+closing)
+			pos = closing
+		}
+	}
+}
+
+// findHeadings records the anchor of every ATX and setext heading
+func (d *markdownDoc) findHeadings() {
+	seen := make(map[string]int)
+	for i := range d.lineStarts {
+		if d.inCode[i] {
+			continue
+		}
+		// Lines blanked by a comment hold no heading
+		masked := strings.TrimRight(string(d.maskedLine(i)), "\r")
+		var text string
+		if atxHeading.MatchString(masked) {
+			text = atxHeading.FindStringSubmatch(d.line(i))[1]
+		} else if i+1 < len(d.lineStarts) && !d.inCode[i+1] && setextUnderline.MatchString(d.line(i+1)) &&
+			!notSetext.MatchString(masked) {
+			text = strings.TrimSpace(masked)
+		}
+		if text == "" {
+			continue
+		}
+
+		if match := headingID.FindStringSubmatch(text); match != nil {
+			d.anchors[match[1]] = true
+			continue
+		}
+		slug := HeadingSlug(text)
+		// Repeated headings get -1, -2 and so on
+		if n, dup := seen[slug]; dup {
+			seen[slug] = n + 1
+			slug = fmt.Sprintf("%s-%d", slug, n+1)
+		} else {
+			seen[slug] = 0
+		}
+		d.anchors[slug] = true
+	}
+}
+
+// HeadingSlug returns the anchor GitHub gives a heading: its text without
+// markup, lowercased, with punctuation removed and spaces turned into
+// hyphens. It does not number repeated headings.
+func HeadingSlug(heading string) string {
+	// Images have no text in a heading
+	text := stripMarkup(heading, false)
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r == ' ':
+			b.WriteRune('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// stripMarkup replaces links with their text, images with their alt text
+// or nothing, and drops HTML tags, innermost first
+func stripMarkup(text string, altText bool) string {
+	for {
+		stripped := inlineMarkup.ReplaceAllStringFunc(text, func(markup string) string {
+			match := inlineMarkup.FindStringSubmatch(markup)
+			if match[1] == "!" && !altText {
+				return ""
+			}
+			return match[2]
+		})
+		if stripped == text {
+			return text
+		}
+		text = stripped
+	}
+}
+
+// plainText turns the text of a link into what a reader sees
+func plainText(text string) string {
+	text = strings.NewReplacer("*", "", "`", "", "\\", "").Replace(stripMarkup(text, true))
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// findAutolinks records urls in angle brackets
+func (d *markdownDoc) findAutolinks() {
+	for _, match := range autolink.FindAllSubmatchIndex(d.masked, -1) {
+		url := string(d.masked[match[2]:match[3]])
+		d.add(Link{URL: url, Element: "a", Attribute: "href", Kind: KindAnchor, Text: url}, match[0])
+		d.blank(match[0], match[1])
+	}
+}
+
+// findDefinitions records reference definitions. A definition is an image
+// when only image references use it.
+func (d *markdownDoc) findDefinitions() {
+	images, links := d.referenceUses()
+	for i := range d.lineStarts {
+		line := d.maskedLine(i)
+		match := referenceDefinition.FindSubmatchIndex(line)
+		if match == nil {
+			continue
+		}
+		start, end := d.lineBounds(i)
+		label := string(line[match[2]:match[3]])
+		url := strings.TrimSuffix(strings.TrimPrefix(string(line[match[4]:match[5]]), "<"), ">")
+		link := Link{URL: url, Element: "a", Attribute: "href", Kind: KindAnchor, Text: plainText(label)}
+		if key := referenceLabel(label); images[key] && !links[key] {
+			link.Element, link.Attribute, link.Kind = "img", "src", KindImage
+		}
+		d.add(link, start+match[4])
+		d.blank(start, end)
+	}
+}
+
+// referenceUses returns the labels used by image references and by link
+// references: [text][label], [label][] and a bare [label]
+func (d *markdownDoc) referenceUses() (images, links map[string]bool) {
+	images, links = make(map[string]bool), make(map[string]bool)
+	for i := range d.lineStarts {
+		line := d.maskedLine(i)
+		// Brackets holding the label of a reference already counted
+		labels := make(map[int]bool)
+		for pos := 0; pos < len(line); pos++ {
+			if line[pos] != '[' || (pos > 0 && line[pos-1] == '\\') || labels[pos] {
+				continue
+			}
+			textEnd := closingBracket(line, pos)
+			if textEnd < 0 {
+				continue
+			}
+			label := string(line[pos+1 : textEnd])
+			image := pos > 0 && line[pos-1] == '!'
+			if next := textEnd + 1; next < len(line) && (line[next] == '(' || line[next] == ':') {
+				// An inline link or a definition
+				continue
+			} else if next < len(line) && line[next] == '[' {
+				labels[next] = true
+				if labelEnd := closingBracket(line, next); labelEnd > next+1 {
+					label = string(line[next+1 : labelEnd])
+				}
+			}
+			if image {
+				images[referenceLabel(label)] = true
+			} else {
+				links[referenceLabel(label)] = true
+			}
+		}
+	}
+	return images, links
+}
+
+// referenceLabel normalizes a label so references find their definition
+func referenceLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// findInlineLinks records [text](url) links and ![alt](url) images. The
+// text of a link is searched too, so an image inside a link is found.
+func (d *markdownDoc) findInlineLinks() {
+	for i := range d.lineStarts {
+		line := d.maskedLine(i)
+		lineStart, _ := d.lineBounds(i)
+		for pos := 0; pos < len(line); pos++ {
+			if line[pos] != '[' || (pos > 0 && line[pos-1] == '\\') {
+				continue
+			}
+			textEnd := closingBracket(line, pos)
+			if textEnd < 0 || textEnd+1 >= len(line) || line[textEnd+1] != '(' {
+				continue
+			}
+			destStart, destEnd, end := linkDestination(line, textEnd+2)
+			if end < 0 {
+				continue
+			}
+
+			start := pos
+			link := Link{URL: string(line[destStart:destEnd]), Element: "a", Attribute: "href", Kind: KindAnchor}
+			if pos > 0 && line[pos-1] == '!' {
+				start = pos - 1
+				link.Element, link.Attribute, link.Kind = "img", "src", KindImage
+			}
+			link.Text = plainText(d.source[lineStart+pos+1 : lineStart+textEnd])
+			d.add(link, lineStart+start)
+			d.blank(lineStart+textEnd+1, lineStart+end)
+			d.linkText = append(d.linkText, [2]int{lineStart + pos, lineStart + textEnd})
+		}
+	}
+}
+
+// closingBracket returns the index of the ] matching the [ at open, or -1
+func closingBracket(line []byte, open int) int {
+	depth := 0
+	for i := open; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// linkDestination parses the `url "title")` after a link's opening
+// parenthesis at pos. It returns the bounds of the url and the index just
+// past the closing parenthesis, or an end of -1 when this is not a link.
+func linkDestination(line []byte, pos int) (start, end, after int) {
+	pos = skipSpace(line, pos)
+	if pos < len(line) && line[pos] == '<' {
+		// <url> may hold spaces
+		start = pos + 1
+		close := bytes.IndexByte(line[start:], '>')
+		if close < 0 {
+			return 0, 0, -1
+		}
+		end = start + close
+		pos = end + 1
+	} else {
+		// Parentheses in a url must balance
+		start = pos
+		depth := 0
+		for ; pos < len(line) && line[pos] > ' '; pos++ {
+			if line[pos] == '\\' {
+				pos++
+			} else if line[pos] == '(' {
+				depth++
+			} else if line[pos] == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+		}
+		if depth != 0 {
+			return 0, 0, -1
+		}
+		pos = min(pos, len(line))
+		end = pos
+	}
+
+	// An optional title, in quotes or parentheses
+	pos = skipSpace(line, pos)
+	if pos < len(line) && pos > end && (line[pos] == '"' || line[pos] == '\'' || line[pos] == '(') {
+		closer := line[pos]
+		if closer == '(' {
+			closer = ')'
+		}
+		close := bytes.IndexByte(line[pos+1:], closer)
+		if close < 0 {
+			return 0, 0, -1
+		}
+		pos = skipSpace(line, pos+close+2)
+	}
+	if pos >= len(line) || line[pos] != ')' {
+		return 0, 0, -1
+	}
+	return start, end, pos + 1
+}
+
+// skipSpace returns the index of the first byte from pos that is not a
+// space or tab
+func skipSpace(line []byte, pos int) int {
+	for pos < len(line) && (line[pos] == ' ' || line[pos] == '\t') {
+		pos++
+	}
+	return pos
+}
+
+// findHTML records the links and ids of raw HTML, then blanks its tags
+func (d *markdownDoc) findHTML() {
+	z := html.NewTokenizer(bytes.NewReader(bytes.Clone(d.masked)))
+	offset := 0
+	// Links of the <a> being read, waiting for its text
+	var open []int
+	var text strings.Builder
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return
+		}
+		start := offset
+		offset += len(z.Raw())
+
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			if id := attrValue(token.Attr, "id"); id != "" {
+				d.anchors[id] = true
+			}
+			if name := attrValue(token.Attr, "name"); token.Data == "a" && name != "" {
+				d.anchors[name] = true
+			}
+			for _, link := range elementLinks(token.Data, token.Attr) {
+				if token.Data == "img" || token.Data == "area" {
+					link.Text = attrValue(token.Attr, "alt")
+				}
+				d.add(link, start)
+				if token.Data == "a" && tt == html.StartTagToken {
+					open = append(open, len(d.links)-1)
+				}
+			}
+			d.blank(start, offset)
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "a" {
+				for _, i := range open {
+					if i < len(d.links) {
+						d.links[i].Text = plainText(text.String())
+					}
+				}
+				open = nil
+				text.Reset()
+			}
+			d.blank(start, offset)
+		case html.TextToken:
+			if len(open) > 0 {
+				text.Write(z.Text())
+			}
+		}
+	}
+}
+
+// inLinkText reports whether offset is inside the text of an inline link
+func (d *markdownDoc) inLinkText(offset int) bool {
+	for _, span := range d.linkText {
+		if offset > span[0] && offset < span[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// findBareURLs records urls written as plain text. Trailing punctuation
+// and unbalanced closing parentheses are left out, as GitHub does, and a
+// url that is the text of an inline link is only that link.
+func (d *markdownDoc) findBareURLs() {
+	for _, match := range bareURL.FindAllIndex(d.masked, -1) {
+		if d.inLinkText(match[0]) {
+			continue
+		}
+		if match[0] > 0 {
+			if r, _ := utf8.DecodeLastRune(d.masked[:match[0]]); unicode.IsLetter(r) || unicode.IsNumber(r) {
+				continue
+			}
+		}
+		url := string(d.masked[match[0]:match[1]])
+		for {
+			trimmed := strings.TrimRight(url, "?!.,:;*_~'\"")
+			if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, ")") > strings.Count(trimmed, "(") {
+				trimmed = trimmed[:len(trimmed)-1]
+			}
+			if trimmed == url {
+				break
+			}
+			url = trimmed
+		}
+		text := url
+		if strings.HasPrefix(url, "www.") {
+			url = "http://" + url
+		}
+		d.add(Link{URL: url, Element: "a", Attribute: "href", Kind: KindAnchor, Text: text}, match[0])
+	}
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const markdownDoc1 = `---
+title: "[not](a-link.md)"
+---
+# Project [![CI](https://ci.example.com/badge.svg)](https://ci.example.com/build)
+
+See the [install guide](docs/install.md#requirements "Install") and
+![diagram](<images/flow chart.png>).
+
+Read [the docs][docs], the [FAQ][] or just [api].
+![logo][logo-img]
+
+[docs]: https://docs.example.com
+[faq]: <faq.md>
+[api]: ./api.md 'API'
+[logo-img]: /assets/logo.png
+
+Plain https://example.com/plain, and (https://example.com/paren) or www.example.org.
+Autolinked <https://example.com/auto> and <mailto:team@example.com>.
+
+` + "```" + `html
+<a href="/in-code">[also](in-code.md)</a>
+` + "```" + `
+
+Inline ` + "`[code](span.md)`" + ` and <!-- [hidden](comment.md) --> text.
+
+<p align="center">
+  <img src="media/logo.svg"
+       alt="Logo" width="100">
+  <a href="https://example.com/html" id="custom">HTML link</a>
+</p>
+
+Setext Heading
+--------------
+
+## Repeated
+## Repeated
+### Hugo style {#custom-id}
+[https://example.com/a](https://example.com/a) and [see www.example.net](https://example.net)
+`
+
+func TestParseMarkdown(t *testing.T) {
+	page := ParseMarkdown(markdownDoc1)
+
+	expected := []string{
+		"4:11 a href anchor https://ci.example.com/build CI",
+		"4:12 img src image https://ci.example.com/badge.svg CI",
+		"6:9 a href anchor docs/install.md#requirements install guide",
+		"7:1 img src image images/flow chart.png diagram",
+		"12:9 a href anchor https://docs.example.com docs",
+		"13:8 a href anchor faq.md faq",
+		"14:8 a href anchor ./api.md api",
+		"15:13 img src image /assets/logo.png logo-img",
+		"17:7 a href anchor https://example.com/plain https://example.com/plain",
+		"17:39 a href anchor https://example.com/paren https://example.com/paren",
+		"17:69 a href anchor http://www.example.org www.example.org",
+		"18:12 a href anchor https://example.com/auto https://example.com/auto",
+		"27:3 img src image media/logo.svg Logo",
+		"29:3 a href anchor https://example.com/html HTML link",
+		"38:1 a href anchor https://example.com/a https://example.com/a",
+		"38:52 a href anchor https://example.net see www.example.net",
+	}
+	var got []string
+	for _, link := range page.Links {
+		got = append(got, fmt.Sprintf("%d:%d %s %s %s %s %s", link.Line, link.Column, link.Element, link.Attribute, link.Kind, link.URL, link.Text))
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected links:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	for _, anchor := range []string{"project-", "setext-heading", "repeated", "repeated-1", "custom-id", "custom"} {
+		if !page.Anchors[anchor] {
+			t.Errorf("expected anchor %q in %v", anchor, page.Anchors)
+		}
+	}
+	if page.Anchors["hugo-style-custom-id"] {
+		t.Errorf("expected an explicit id to replace the slug")
+	}
+}
+
+func TestHeadingSlug(t *testing.T) {
+	tests := map[string]string{
+		"Getting Started":             "getting-started",
+		"What's new in v2.0?":         "whats-new-in-v20",
+		"The `--depth` flag":          "the---depth-flag",
+		"See [the docs](docs.md) now": "see-the-docs-now",
+		"snake_case and CamelCase":    "snake_case-and-camelcase",
+		"Übersicht & Ärger":           "übersicht--ärger",
+		"<img src=\"x.png\"> Logo":    "-logo",
+		"Emoji 🚀 launch":              "emoji--launch",
+	}
+	for heading, slug := range tests {
+		if got := HeadingSlug(heading); got != slug {
+			t.Errorf("HeadingSlug(%q) = %q, expected %q", heading, got, slug)
+		}
+	}
+}
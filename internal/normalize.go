@@ -0,0 +1,173 @@
+package internal
+
+import (
+	neturl "net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Trailing slash policies
+const (
+	SlashKeep  = "keep"  // leave paths as written
+	SlashAdd   = "add"   // /about becomes /about/, except for file names such as /logo.png
+	SlashStrip = "strip" // /about/ becomes /about
+)
+
+// SlashPolicies lists every accepted trailing slash policy
+var SlashPolicies = []string{SlashKeep, SlashAdd, SlashStrip}
+
+// NormalizeOptions controls which spellings of a url count as the same
+type NormalizeOptions struct {
+	StripParams   []string // query parameters to drop; a trailing * matches a prefix
+	SortQuery     bool     // order query parameters by name
+	TrailingSlash string   // SlashKeep, SlashAdd or SlashStrip
+}
+
+// DefaultNormalizeOptions returns the options used when none are given
+func DefaultNormalizeOptions() NormalizeOptions {
+	return NormalizeOptions{
+		StripParams:   []string{"utm_*", "gclid", "fbclid"},
+		SortQuery:     true,
+		TrailingSlash: SlashKeep,
+	}
+}
+
+// Normalizer rewrites urls into a canonical form so that different
+// spellings of one page are fetched and checked once. A nil Normalizer
+// only removes fragments for fetching.
+type Normalizer struct {
+	opts NormalizeOptions
+}
+
+func NewNormalizer(opts NormalizeOptions) *Normalizer {
+	return &Normalizer{opts: opts}
+}
+
+// Normalize returns the canonical form of an absolute http or https url:
+// lowercase scheme and host, no default port, no dot segments, tracking
+// parameters stripped, query sorted and the trailing slash policy applied.
+// The fragment is kept, since different fragments are different links.
+// Anything else is returned unchanged.
+func (n *Normalizer) Normalize(rawURL string) string {
+	if n == nil {
+		return rawURL
+	}
+	u, err := neturl.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return rawURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+
+	// Work on the escaped path so encoded characters such as %2F survive
+	escaped := removeDotSegments(u.EscapedPath())
+	if escaped == "" {
+		escaped = "/"
+	}
+	switch n.opts.TrailingSlash {
+	case SlashAdd:
+		if !strings.HasSuffix(escaped, "/") && !strings.Contains(path.Base(escaped), ".") {
+			escaped += "/"
+		}
+	case SlashStrip:
+		if escaped != "/" {
+			escaped = strings.TrimRight(escaped, "/")
+		}
+	}
+	if unescaped, err := neturl.PathUnescape(escaped); err == nil {
+		u.Path, u.RawPath = unescaped, escaped
+	}
+
+	u.RawQuery = n.normalizeQuery(u.RawQuery)
+	u.ForceQuery = false
+	if u.Fragment == "" {
+		u.RawFragment = ""
+	}
+	return u.String()
+}
+
+// PageURL returns the canonical url without its fragment: the address
+// actually requested, shared by every fragment of the page
+func (n *Normalizer) PageURL(rawURL string) string {
+	return pageKey(n.Normalize(rawURL))
+}
+
+// normalizeQuery drops stripped parameters and sorts the rest, working on
+// the raw pairs so their original encoding is kept
+func (n *Normalizer) normalizeQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	var pairs []string
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(pair, "=")
+		if decoded, err := neturl.QueryUnescape(name); err == nil {
+			name = decoded
+		}
+		if n.stripped(name) {
+			continue
+		}
+		pairs = append(pairs, pair)
+	}
+	if n.opts.SortQuery {
+		// Stable, so repeated parameters keep their relative order
+		sort.SliceStable(pairs, func(i, j int) bool {
+			nameI, _, _ := strings.Cut(pairs[i], "=")
+			nameJ, _, _ := strings.Cut(pairs[j], "=")
+			return nameI < nameJ
+		})
+	}
+	return strings.Join(pairs, "&")
+}
+
+// stripped reports whether a query parameter is removed
+func (n *Normalizer) stripped(name string) bool {
+	for _, pattern := range n.opts.StripParams {
+		if prefix, wildcard := strings.CutSuffix(pattern, "*"); wildcard {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// removeDotSegments resolves "." and ".." in a path as RFC 3986 section
+// 5.2.4 describes, keeping any trailing slash
+func removeDotSegments(p string) string {
+	if !strings.Contains(p, ".") {
+		return p
+	}
+	var out []string
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			// Never climb above the root
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, segment)
+		}
+	}
+	return strings.Join(out, "/")
+}
@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNormalizer_Normalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     NormalizeOptions
+		input    string
+		expected string
+	}{
+		{"lowercase scheme and host", DefaultNormalizeOptions(), "HTTP://Example.COM/About", "http://example.com/About"},
+		{"default http port", DefaultNormalizeOptions(), "http://example.com:80/a", "http://example.com/a"},
+		{"default https port", DefaultNormalizeOptions(), "https://example.com:443/a", "https://example.com/a"},
+		{"other port kept", DefaultNormalizeOptions(), "https://example.com:8443/a", "https://example.com:8443/a"},
+		{"empty path", DefaultNormalizeOptions(), "https://example.com", "https://example.com/"},
+		{"dot segments", DefaultNormalizeOptions(), "https://example.com/a/./b/../c", "https://example.com/a/c"},
+		{"dot segments above root", DefaultNormalizeOptions(), "https://example.com/../../a", "https://example.com/a"},
+		{"trailing dot segment", DefaultNormalizeOptions(), "https://example.com/a/b/..", "https://example.com/a/"},
+		{"encoded slash kept", DefaultNormalizeOptions(), "https://example.com/a%2Fb", "https://example.com/a%2Fb"},
+		{"fragment kept", DefaultNormalizeOptions(), "https://example.com/a#Team", "https://example.com/a#Team"},
+		{"empty fragment dropped", DefaultNormalizeOptions(), "https://example.com/a#", "https://example.com/a"},
+		{"empty query dropped", DefaultNormalizeOptions(), "https://example.com/a?", "https://example.com/a"},
+		{"tracking params stripped", DefaultNormalizeOptions(), "https://example.com/a?utm_source=x&id=1&gclid=y", "https://example.com/a?id=1"},
+		{"query sorted", DefaultNormalizeOptions(), "https://example.com/a?b=2&a=1&b=1", "https://example.com/a?a=1&b=2&b=1"},
+		{"query order kept", NormalizeOptions{}, "https://example.com/a?b=2&a=1&utm_source=x", "https://example.com/a?b=2&a=1&utm_source=x"},
+		{"exact param", NormalizeOptions{StripParams: []string{"ref"}}, "https://example.com/?ref=a&referrer=b", "https://example.com/?referrer=b"},
+		{"add slash", NormalizeOptions{TrailingSlash: SlashAdd}, "https://example.com/about", "https://example.com/about/"},
+		{"add slash skips files", NormalizeOptions{TrailingSlash: SlashAdd}, "https://example.com/logo.png", "https://example.com/logo.png"},
+		{"strip slash", NormalizeOptions{TrailingSlash: SlashStrip}, "https://example.com/about/", "https://example.com/about"},
+		{"strip slash keeps root", NormalizeOptions{TrailingSlash: SlashStrip}, "https://example.com/", "https://example.com/"},
+		{"not http", DefaultNormalizeOptions(), "MAILTO:Someone@Example.com", "MAILTO:Someone@Example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewNormalizer(tt.opts).Normalize(tt.input); got != tt.expected {
+				t.Errorf("Normalize(%q) = %q, expected %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizer_PageURL(t *testing.T) {
+	n := NewNormalizer(DefaultNormalizeOptions())
+	if got := n.PageURL("HTTPS://Example.com:443/a/../b?utm_medium=x#top"); got != "https://example.com/b" {
+		t.Errorf("PageURL() = %q", got)
+	}
+
+	// A nil normalizer leaves urls alone apart from the fragment
+	var none *Normalizer
+	if got := none.Normalize("HTTP://Example.com/a#b"); got != "HTTP://Example.com/a#b" {
+		t.Errorf("nil Normalize() = %q", got)
+	}
+	if got := none.PageURL("HTTP://Example.com/a#b"); got != "HTTP://Example.com/a" {
+		t.Errorf("nil PageURL() = %q", got)
+	}
+}
+
+func TestCrawlSite_Normalized(t *testing.T) {
+	var mu sync.Mutex
+	fetches := make(map[string]int)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches[r.URL.RequestURI()]++
+		mu.Unlock()
+		if r.URL.Path == "/" {
+			upper := strings.Replace(server.URL, "http://", "HTTP://", 1)
+			w.Write([]byte(`<a href="/about">a</a><a href="/about/">b</a><a href="/about#team">c</a>` +
+				`<a href="` + upper + `/about">d</a><a href="/about?utm_source=x">e</a><a href="./x/../about">f</a>` +
+				`<a href="/team/?utm_source=x&b=2&a=1">g</a><a href="/team?a=1&b=2">h</a>`))
+			return
+		}
+		w.Write([]byte(`<div id="team"></div>`))
+	}))
+	defer server.Close()
+
+	opts := CrawlOptions{MaxDepth: 2, Workers: 4}
+	opts.Normalizer = NewNormalizer(NormalizeOptions{StripParams: []string{"utm_*"}, SortQuery: true, TrailingSlash: SlashStrip})
+	result := CrawlSite(server.URL, opts)
+
+	expected := []string{server.URL + "/about", server.URL + "/about#team", server.URL + "/team?a=1&b=2"}
+	if strings.Join(result.Links, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected links %v, got %v", expected, result.Links)
+	}
+	if fetches["/about"] != 1 {
+		t.Errorf("expected /about to be fetched once, got %d", fetches["/about"])
+	}
+
+	// The canonical form only groups spellings; the first one is requested
+	written := server.URL + "/team/?utm_source=x&b=2&a=1"
+	if fetches["/team/?utm_source=x&b=2&a=1"] != 1 || fetches["/team?a=1&b=2"] != 0 {
+		t.Errorf("expected the team page to be fetched as first written, got %v", fetches)
+	}
+	if spelling := result.Spellings[server.URL+"/team?a=1&b=2"]; spelling != written {
+		t.Errorf("expected the team link to be requested as %q, got %q", written, spelling)
+	}
+	if _, ok := result.Spellings[server.URL+"/about"]; ok {
+		t.Errorf("expected no spelling for a link normalizing left alone")
+	}
+
+	// Every source keeps the link as it was written
+	var originals []string
+	for _, source := range result.Sources[server.URL+"/about"] {
+		originals = append(originals, source.Original)
+	}
+	if len(originals) != 5 || originals[1] != "/about/" || originals[4] != "./x/../about" {
+		t.Errorf("unexpected original hrefs %q", originals)
+	}
+}
+
+func TestCheckLinks_Normalized(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.RequestURI()]++
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	urls := []string{server.URL + "/a", server.URL + "/a#one", server.URL + "/./a?utm_source=x", server.URL + "/b?z=1&y=2", server.URL + "/b?y=2&z=1",
+		server.URL + "/c?a=1&b=2"}
+	opts := CheckOptions{Concurrency: 4, PerHost: 4, Normalizer: NewNormalizer(DefaultNormalizeOptions())}
+	// A link the crawl found written another way is requested that way
+	opts.Spellings = map[string]string{server.URL + "/c?a=1&b=2": server.URL + "/c/?b=2&a=1&utm_source=x#top"}
+	results := CheckLinks(&urls, opts)
+
+	// One request per canonical page, using the first spelling
+	expected := map[string]int{"/a": 1, "/b?z=1&y=2": 1, "/c/?b=2&a=1&utm_source=x": 1}
+	if len(requests) != len(expected) {
+		t.Errorf("expected requests %v, got %v", expected, requests)
+	}
+	for uri, count := range expected {
+		if requests[uri] != count {
+			t.Errorf("expected %q to be requested %d times, got %v", uri, count, requests)
+		}
+	}
+	for i, result := range results {
+		if result.URL != urls[i] || result.Dead {
+			t.Errorf("expected %q to be reported alive as written, got %+v", urls[i], result)
+		}
+	}
+}
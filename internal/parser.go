@@ -4,11 +4,87 @@ import (
 	"fmt"
 	"golang.org/x/net/html"
 	"strings"
+	"unicode/utf8"
 )
 
+// Link is a single link found in an HTML document.
+type Link struct {
+	URL       string // cleaned attribute value, possibly relative
+	Element   string // tag the link came from, e.g. "a"
+	Attribute string // attribute holding the link, e.g. "href"
+	Kind      string // what sort of resource the link points at, e.g. "image"
+	Text      string // visible text of the element, or alt text for images
+	Line      int    // 1-based line of the start tag, 0 if unknown
+	Column    int    // 1-based column of the start tag, 0 if unknown
+}
+
+// Link kinds, used to filter which links get checked
+const (
+	KindAnchor     = "anchor"
+	KindImage      = "image"
+	KindStylesheet = "link"
+	KindScript     = "script"
+	KindFrame      = "frame"
+	KindMedia      = "media"
+	KindEmbed      = "embed"
+	KindForm       = "form"
+	KindCite       = "cite"
+	KindSitemap    = "sitemap"
+)
+
+// LinkKinds lists every kind a link can be tagged with
+var LinkKinds = []string{
+	KindAnchor, KindImage, KindStylesheet, KindScript, KindFrame,
+	KindMedia, KindEmbed, KindForm, KindCite, KindSitemap,
+}
+
+// linkAttribute is an attribute that holds a url on a given element
+type linkAttribute struct {
+	name string
+	kind string
+}
+
+// linkElements maps each resource-bearing element to its url attributes,
+// in the order they are reported.
+var linkElements = map[string][]linkAttribute{
+	"a":          {{"href", KindAnchor}},
+	"area":       {{"href", KindAnchor}},
+	"img":        {{"src", KindImage}, {"srcset", KindImage}},
+	"link":       {{"href", KindStylesheet}},
+	"script":     {{"src", KindScript}},
+	"iframe":     {{"src", KindFrame}},
+	"video":      {{"src", KindMedia}, {"poster", KindImage}},
+	"audio":      {{"src", KindMedia}},
+	"source":     {{"src", KindMedia}, {"srcset", KindImage}},
+	"track":      {{"src", KindMedia}},
+	"object":     {{"data", KindEmbed}},
+	"embed":      {{"src", KindEmbed}},
+	"form":       {{"action", KindForm}},
+	"blockquote": {{"cite", KindCite}},
+	"q":          {{"cite", KindCite}},
+}
+
+// Page is what the parser extracts from a single HTML document.
+type Page struct {
+	URL      string // where the page was served from after redirects, "" when not fetched
+	Links    []Link
+	BaseHref string          // href of the first <base> element, "" if there is none
+	Anchors  map[string]bool // fragment targets: every id, and the name of each <a>
+}
+
 // ParseLinks parses the HTML content and extracts all links.
-func ParseLinks(htmlContent string) ([]string, error) {
-	var links []string
+func ParseLinks(htmlContent string) ([]Link, error) {
+	page, err := ParsePage(htmlContent)
+	if err != nil {
+		return nil, err
+	}
+	return page.Links, nil
+}
+
+// ParsePage parses the HTML content and extracts its links together with
+// the document's <base> href, which relative links resolve against.
+func ParsePage(htmlContent string) (*Page, error) {
+	var links []Link
 	// Convert htmlString into a strings.reader
 	htmlReader := strings.NewReader(htmlContent)
 	doc, err := html.Parse(htmlReader)
@@ -17,18 +93,62 @@ func ParseLinks(htmlContent string) ([]string, error) {
 	}
 	// Call traverse nodes
 	traverseNodes(doc, &links)
-	return links, nil
+	// The parsed tree has no positions, so fill them in from the raw source
+	attachPositions(links, tagPositions(htmlContent))
+	anchors := make(map[string]bool)
+	findAnchors(doc, anchors)
+	return &Page{Links: links, BaseHref: findBaseHref(doc), Anchors: anchors}, nil
+}
+
+// findAnchors records every fragment a browser could scroll to: the id of
+// any element and the name of <a> elements.
+func findAnchors(n *html.Node, anchors map[string]bool) {
+	if n.Type == html.ElementNode {
+		if id, ok := lookupAttr(n.Attr, "id"); ok && id != "" {
+			anchors[id] = true
+		}
+		if n.Data == "a" {
+			if name, ok := lookupAttr(n.Attr, "name"); ok && name != "" {
+				anchors[name] = true
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		findAnchors(c, anchors)
+	}
+}
+
+// findBaseHref returns the href of the first <base> element that has one.
+// Later base elements are ignored, as browsers do.
+func findBaseHref(n *html.Node) string {
+	if n.Type == html.ElementNode && n.Data == "base" {
+		if href, ok := lookupAttr(n.Attr, "href"); ok {
+			return strings.TrimSpace(href)
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if href := findBaseHref(c); href != "" {
+			return href
+		}
+	}
+	return ""
 }
 
 // traverseNodes recursively traverses the HTML nodes to find links.
-func traverseNodes(n *html.Node, links *[]string) {
-	// Check if the current node is an anchor tage
-	if n.Type == html.ElementNode && n.Data == "a" {
-		// Extract the href
-		href := extractHref(n)
-		// If it is valid (string will not be empty), then append to array
-		if href != "" {
-			*links = append(*links, href)
+func traverseNodes(n *html.Node, links *[]Link) {
+	// Check if the current node is a resource-bearing element
+	if n.Type == html.ElementNode {
+		found := elementLinks(n.Data, n.Attr)
+		if len(found) > 0 {
+			text := nodeText(n)
+			// Images have no text of their own, so describe them by alt text
+			if n.Data == "img" || n.Data == "area" {
+				text = attrValue(n.Attr, "alt")
+			}
+			for i := range found {
+				found[i].Text = text
+			}
+			*links = append(*links, found...)
 		}
 	}
 	// Recurse on child and sibling nodes
@@ -38,6 +158,105 @@ func traverseNodes(n *html.Node, links *[]string) {
 
 }
 
+// elementLinks returns the links carried by an element's attributes, in
+// linkElements order. Positions and text are left for the caller to fill.
+func elementLinks(tag string, attrs []html.Attribute) []Link {
+	var links []Link
+
+	// Resource hints name an origin to warm up, not a resource to fetch
+	if tag == "link" {
+		rel := strings.ToLower(attrValue(attrs, "rel"))
+		if strings.Contains(rel, "preconnect") || strings.Contains(rel, "dns-prefetch") {
+			return nil
+		}
+	}
+	// Only a GET form can be requested like a link; a POST-only endpoint
+	// answers HEAD and GET with 405 even when it works
+	if tag == "form" {
+		if method := strings.ToLower(strings.TrimSpace(attrValue(attrs, "method"))); method != "" && method != "get" {
+			return nil
+		}
+	}
+
+	for _, attribute := range linkElements[tag] {
+		value, ok := lookupAttr(attrs, attribute.name)
+		if !ok {
+			continue
+		}
+
+		values := []string{value}
+		if attribute.name == "srcset" {
+			values = parseSrcset(value)
+		}
+		for _, v := range values {
+			// clean the URL and drop the ones we cannot check
+			if cleaned := cleanURL(v); cleaned != "" {
+				links = append(links, Link{
+					URL:       cleaned,
+					Element:   tag,
+					Attribute: attribute.name,
+					Kind:      attribute.kind,
+				})
+			}
+		}
+	}
+	return links
+}
+
+// lookupAttr finds an attribute by name.
+func lookupAttr(attrs []html.Attribute, name string) (string, bool) {
+	for _, a := range attrs {
+		if a.Namespace == "" && a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// attrValue returns an attribute's value, or "" if it is missing.
+func attrValue(attrs []html.Attribute, name string) string {
+	value, _ := lookupAttr(attrs, name)
+	return value
+}
+
+// parseSrcset returns the urls from a srcset attribute. Each candidate is
+// a url followed by optional descriptors such as "2x" or "480w"; commas
+// inside a url are kept unless they end it.
+func parseSrcset(srcset string) []string {
+	var urls []string
+	rest := srcset
+	for {
+		// Skip separators before the next candidate
+		rest = strings.TrimLeft(rest, " \t\n\r\f,")
+		if rest == "" {
+			return urls
+		}
+
+		// The url runs to the next whitespace
+		end := strings.IndexAny(rest, " \t\n\r\f")
+		if end < 0 {
+			end = len(rest)
+		}
+		url := rest[:end]
+		rest = rest[end:]
+
+		if trimmed := strings.TrimRight(url, ","); trimmed != url {
+			// A trailing comma ends the candidate with no descriptors
+			url = trimmed
+		} else {
+			// Skip descriptors up to the comma that ends the candidate
+			if comma := strings.Index(rest, ","); comma >= 0 {
+				rest = rest[comma+1:]
+			} else {
+				rest = ""
+			}
+		}
+		if url != "" {
+			urls = append(urls, url)
+		}
+	}
+}
+
 // extractHref extracts the href attribute from an anchor tag.
 func extractHref(node *html.Node) string {
 	// Check if node is an HTML node and that it is an anchor tag
@@ -56,6 +275,87 @@ func extractHref(node *html.Node) string {
 
 }
 
+// nodeText returns the text inside a node with whitespace collapsed.
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	var collect func(*html.Node)
+	collect = func(c *html.Node) {
+		if c.Type == html.TextNode {
+			sb.WriteString(c.Data)
+			sb.WriteString(" ")
+		}
+		for child := c.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(n)
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// tagPosition is where a link-bearing start tag appears in the source.
+type tagPosition struct {
+	element string
+	url     string
+	line    int
+	column  int
+}
+
+// tagPositions tokenizes the raw HTML and records the line and column of
+// every start tag that carries a link, in document order.
+func tagPositions(htmlContent string) []tagPosition {
+	var positions []tagPosition
+	z := html.NewTokenizer(strings.NewReader(htmlContent))
+	offset, line, lineStart := 0, 1, 0
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return positions
+		}
+		raw := string(z.Raw())
+		start := offset
+		offset += len(raw)
+
+		if tt == html.StartTagToken || tt == html.SelfClosingTagToken {
+			token := z.Token()
+			column := utf8.RuneCountInString(htmlContent[lineStart:start]) + 1
+			for _, link := range elementLinks(token.Data, token.Attr) {
+				positions = append(positions, tagPosition{
+					element: link.Element,
+					url:     link.URL,
+					line:    line,
+					column:  column,
+				})
+			}
+		}
+
+		// Advance the line counter past this token
+		for i := start; i < offset; i++ {
+			if htmlContent[i] == '\n' {
+				line++
+				lineStart = i + 1
+			}
+		}
+	}
+}
+
+// attachPositions copies source positions onto links. Both lists are in
+// document order, so a single forward scan pairs them up; links the parser
+// invented (e.g. re-opened anchors) keep a zero position.
+func attachPositions(links []Link, positions []tagPosition) {
+	next := 0
+	for i := range links {
+		for j := next; j < len(positions); j++ {
+			if positions[j].element == links[i].Element && positions[j].url == links[i].URL {
+				links[i].Line = positions[j].line
+				links[i].Column = positions[j].column
+				next = j + 1
+				break
+			}
+		}
+	}
+}
+
 func cleanURL(url string) string {
 	// Trim whitespace from the URL
 	url = strings.TrimSpace(url)
@@ -66,7 +366,8 @@ func cleanURL(url string) string {
 	// Filter out invalid schemes
 	if strings.HasPrefix(url, "javascript:") ||
 		strings.HasPrefix(url, "mailto:") ||
-		strings.HasPrefix(url, "tel:") {
+		strings.HasPrefix(url, "tel:") ||
+		strings.HasPrefix(url, "data:") {
 		return ""
 	}
 
@@ -79,6 +380,6 @@ func cleanURL(url string) string {
 	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
 		return url
 	}
-	
+
 	return url
 }
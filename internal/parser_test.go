@@ -2,6 +2,7 @@ package internal
 
 import (
 	"golang.org/x/net/html"
+	"strings"
 	"testing"
 )
 
@@ -18,6 +19,7 @@ func TestCleanURL(t *testing.T) {
 		{"mailto:test@example.com", ""},
 		{"javascript:alert('x')", ""},
 		{"tel:1234567890", ""},
+		{"data:image/png;base64,iVBORw0KGgo=", ""},
 		{"", ""},
 	}
 	for _, tt := range tests {
@@ -107,7 +109,7 @@ func TestParseLinks(t *testing.T) {
 				t.Errorf("ParseLinks() got %d links, want %d", len(result), len(tt.expected))
 			}
 			for i, link := range result {
-				if i >= len(tt.expected) || link != tt.expected[i] {
+				if i >= len(tt.expected) || link.URL != tt.expected[i] {
 					t.Errorf("ParseLinks() = %v, want %v", result, tt.expected)
 					break
 				}
@@ -132,14 +134,200 @@ func TestTraverseNodes(t *testing.T) {
 	div.FirstChild = anchor
 	anchor.Parent = div
 
-	var links []string
+	var links []Link
 	traverseNodes(div, &links)
 
 	expected := []string{"http://example.com"}
 	if len(links) != len(expected) {
 		t.Errorf("traverseNodes() got %d links, want %d", len(links), len(expected))
 	}
-	if len(links) > 0 && links[0] != expected[0] {
+	if len(links) > 0 && links[0].URL != expected[0] {
 		t.Errorf("traverseNodes() = %v, want %v", links, expected)
 	}
 }
+
+func TestParseLinks_SourceDetails(t *testing.T) {
+	htmlContent := "<html>\n<body>\n  <p>Intro <a href=\"/first\">First <b>page</b></a></p>\n<a\n href=\"/second\">\n  Second\n</a>\n<a href=\"/first\">Again</a>\n</body></html>"
+
+	links, err := ParseLinks(htmlContent)
+	if err != nil {
+		t.Fatalf("ParseLinks() error = %v", err)
+	}
+
+	expected := []Link{
+		{URL: "/first", Element: "a", Attribute: "href", Kind: KindAnchor, Text: "First page", Line: 3, Column: 12},
+		{URL: "/second", Element: "a", Attribute: "href", Kind: KindAnchor, Text: "Second", Line: 4, Column: 1},
+		{URL: "/first", Element: "a", Attribute: "href", Kind: KindAnchor, Text: "Again", Line: 8, Column: 1},
+	}
+	if len(links) != len(expected) {
+		t.Fatalf("ParseLinks() got %d links, want %d: %+v", len(links), len(expected), links)
+	}
+	for i := range expected {
+		if links[i] != expected[i] {
+			t.Errorf("link %d = %+v, want %+v", i, links[i], expected[i])
+		}
+	}
+}
+
+func TestNodeText(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader("<a href=\"/x\">  Hello\n\t<span>big</span>   world </a>"))
+	if err != nil {
+		t.Fatalf("html.Parse() error = %v", err)
+	}
+	var links []Link
+	traverseNodes(doc, &links)
+	if len(links) != 1 || links[0].Text != "Hello big world" {
+		t.Errorf("expected collapsed anchor text, got %+v", links)
+	}
+}
+
+func TestParseLinks_Elements(t *testing.T) {
+	htmlContent := `<html><head>
+<link rel="stylesheet" href="/style.css">
+<link rel="preconnect" href="https://fonts.gstatic.com">
+<script src="/app.js"></script>
+</head><body>
+<img src="/logo.png" srcset="/logo-2x.png 2x, /logo-3x.png 3x" alt="Logo">
+<picture><source srcset="/hero.webp" type="image/webp"></picture>
+<video src="/intro.mp4" poster="/poster.jpg"><track src="/captions.vtt"></video>
+<audio><source src="/song.mp3"></audio>
+<iframe src="https://player.example.com/embed"></iframe>
+<object data="/doc.pdf"></object><embed src="/movie.swf">
+<map><area href="/region" alt="Region"></map>
+<form action="/search"></form>
+<form action="/contact" method="POST"></form><form action="/find" method="get"></form>
+<blockquote cite="https://source.example.com/quote">Quote</blockquote><q cite="/q">q</q>
+<img src="data:image/gif;base64,R0lGOD">
+</body></html>`
+
+	links, err := ParseLinks(htmlContent)
+	if err != nil {
+		t.Fatalf("ParseLinks() error = %v", err)
+	}
+
+	expected := []struct {
+		url, element, attribute, kind, text string
+	}{
+		{"/style.css", "link", "href", KindStylesheet, ""},
+		{"/app.js", "script", "src", KindScript, ""},
+		{"/logo.png", "img", "src", KindImage, "Logo"},
+		{"/logo-2x.png", "img", "srcset", KindImage, "Logo"},
+		{"/logo-3x.png", "img", "srcset", KindImage, "Logo"},
+		{"/hero.webp", "source", "srcset", KindImage, ""},
+		{"/intro.mp4", "video", "src", KindMedia, ""},
+		{"/poster.jpg", "video", "poster", KindImage, ""},
+		{"/captions.vtt", "track", "src", KindMedia, ""},
+		{"/song.mp3", "source", "src", KindMedia, ""},
+		{"https://player.example.com/embed", "iframe", "src", KindFrame, ""},
+		{"/doc.pdf", "object", "data", KindEmbed, ""},
+		{"/movie.swf", "embed", "src", KindEmbed, ""},
+		{"/region", "area", "href", KindAnchor, "Region"},
+		{"/search", "form", "action", KindForm, ""},
+		{"/find", "form", "action", KindForm, ""},
+		{"https://source.example.com/quote", "blockquote", "cite", KindCite, "Quote"},
+		{"/q", "q", "cite", KindCite, "q"},
+	}
+
+	if len(links) != len(expected) {
+		t.Fatalf("ParseLinks() got %d links, want %d: %+v", len(links), len(expected), links)
+	}
+	for i, want := range expected {
+		got := links[i]
+		if got.URL != want.url || got.Element != want.element || got.Attribute != want.attribute || got.Kind != want.kind || got.Text != want.text {
+			t.Errorf("link %d = %+v, want %+v", i, got, want)
+		}
+		if got.Line == 0 {
+			t.Errorf("link %d (%s) has no position", i, got.URL)
+		}
+	}
+
+	// srcset candidates share the position of their tag
+	if links[3].Line != 6 || links[3].Column != links[2].Column {
+		t.Errorf("expected srcset link on line 6 at the img column, got %+v", links[3])
+	}
+}
+
+func TestParseSrcset(t *testing.T) {
+	tests := []struct {
+		srcset   string
+		expected []string
+	}{
+		{"/a.png", []string{"/a.png"}},
+		{"/a.png 1x, /b.png 2x", []string{"/a.png", "/b.png"}},
+		{" /a.png 480w,\n\t/b.png 800w ", []string{"/a.png", "/b.png"}},
+		{"/a.png,/b.png", []string{"/a.png,/b.png"}},
+		{"/a.png, /b.png", []string{"/a.png", "/b.png"}},
+		{"/img/w_100,h_100/a.png 1x, /b.png 2x", []string{"/img/w_100,h_100/a.png", "/b.png"}},
+		{"", nil},
+		{" , ", nil},
+	}
+
+	for _, tt := range tests {
+		got := parseSrcset(tt.srcset)
+		if len(got) != len(tt.expected) {
+			t.Errorf("parseSrcset(%q) = %q, want %q", tt.srcset, got, tt.expected)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.expected[i] {
+				t.Errorf("parseSrcset(%q) = %q, want %q", tt.srcset, got, tt.expected)
+				break
+			}
+		}
+	}
+}
+
+func TestParsePage_BaseHref(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{"no base", `<html><head></head><body><a href="/x">x</a></body></html>`, ""},
+		{"relative base", `<head><base href=" /docs/ "></head>`, "/docs/"},
+		{"absolute base", `<head><base href="https://example.com/v2/"></head>`, "https://example.com/v2/"},
+		{"first base with href wins", `<head><base target="_blank"><base href="/first/"><base href="/second/"></head>`, "/first/"},
+		{"malformed base kept for the crawler to reject", `<head><base href="ht tp://bad"></head>`, "ht tp://bad"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := ParsePage(tt.html)
+			if err != nil {
+				t.Fatalf("ParsePage() error = %v", err)
+			}
+			if page.BaseHref != tt.expected {
+				t.Errorf("ParsePage().BaseHref = %q, want %q", page.BaseHref, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParsePage_Anchors(t *testing.T) {
+	html := `<html><body>
+<h2 id="install">Install</h2>
+<a name="legacy">Old anchor</a>
+<div id="">empty ids are ignored</div>
+<input name="query">
+<section id="caf&eacute;"></section>
+</body></html>`
+
+	page, err := ParsePage(html)
+	if err != nil {
+		t.Fatalf("ParsePage() error = %v", err)
+	}
+
+	expected := map[string]bool{"install": true, "legacy": true, "café": true}
+	if len(page.Anchors) != len(expected) {
+		t.Errorf("expected anchors %v, got %v", expected, page.Anchors)
+	}
+	for anchor := range expected {
+		if !page.Anchors[anchor] {
+			t.Errorf("expected anchor %q in %v", anchor, page.Anchors)
+		}
+	}
+	// Only <a> elements are targets by name
+	if page.Anchors["query"] {
+		t.Errorf("expected form field names not to be anchors")
+	}
+}
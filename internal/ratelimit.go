@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// adaptiveRate is the rate, in requests per second, a host falls to the
+// first time it answers 429 when no --rate was set
+const adaptiveRate = 2.0
+
+// minAdaptiveRate stops repeated 429s from slowing a host to a standstill
+const minAdaptiveRate = 1.0 / 60
+
+// RateLimiter spaces out requests to each host with a token bucket. One
+// limiter is shared by the crawler and the checker, so a host sees a
+// single request stream however many workers are running. A nil
+// RateLimiter never waits.
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64       // requests per second for each host, 0 for no limit
+	delay   time.Duration // minimum gap between requests to a host
+	buckets map[string]*hostBucket
+}
+
+// hostBucket is the limiter state for a single host
+type hostBucket struct {
+	rate   float64 // may drop below the limiter's rate after a 429
+	burst  float64
+	tokens float64
+	last   time.Time // when tokens was last brought up to date
+	next   time.Time // earliest start for the next request
+}
+
+// NewRateLimiter limits each host to rate requests per second, with at
+// least delay between consecutive requests. Zero disables either limit.
+func NewRateLimiter(rate float64, delay time.Duration) *RateLimiter {
+	if rate < 0 {
+		rate = 0
+	}
+	return &RateLimiter{
+		rate:    rate,
+		delay:   delay,
+		buckets: make(map[string]*hostBucket),
+	}
+}
+
+// bucket returns the state for a host, creating it on first use.
+// Callers must hold l.mu.
+func (l *RateLimiter) bucket(host string, now time.Time) *hostBucket {
+	b, ok := l.buckets[host]
+	if !ok {
+		// Up to one second's worth of requests may go out back to back
+		burst := math.Max(1, math.Floor(l.rate))
+		b = &hostBucket{rate: l.rate, burst: burst, tokens: burst, last: now}
+		l.buckets[host] = b
+	}
+	return b
+}
+
+// Wait blocks until a request to host may start. minGap adds a host
+// specific spacing on top of the limiter's own, such as a robots.txt
+// Crawl-delay. The slot is reserved before sleeping, so concurrent
+// callers queue up in order instead of all waking at once.
+func (l *RateLimiter) Wait(host string, minGap time.Duration) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	b := l.bucket(host, now)
+
+	start := now
+	if b.next.After(start) {
+		start = b.next
+	}
+	if b.rate > 0 {
+		// Refill up to the start time, then wait for a whole token
+		b.tokens = math.Min(b.burst, b.tokens+start.Sub(b.last).Seconds()*b.rate)
+		if b.tokens < 1 {
+			start = start.Add(time.Duration((1 - b.tokens) / b.rate * float64(time.Second)))
+			b.tokens = 1
+		}
+		b.tokens--
+		b.last = start
+	}
+	b.next = start.Add(max(l.delay, minGap))
+	l.mu.Unlock()
+
+	time.Sleep(start.Sub(now))
+}
+
+// Throttle slows a host down after it answered 429 Too Many Requests. The
+// host's rate is halved, or set to a cautious default if it had no limit,
+// and requests pause until retryAfter has passed.
+func (l *RateLimiter) Throttle(host string, retryAfter time.Duration) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	b := l.bucket(host, now)
+
+	if b.rate == 0 {
+		b.rate = adaptiveRate
+	} else {
+		b.rate = math.Max(minAdaptiveRate, b.rate/2)
+	}
+	// No more bursts to a host that is pushing back
+	b.burst = 1
+	b.tokens = 0
+	b.last = now
+	if pause := now.Add(retryAfter); pause.After(b.next) {
+		b.next = pause
+	}
+}
+
+// Rate returns the current requests per second allowed to host, 0 for no limit
+func (l *RateLimiter) Rate(host string) float64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.bucket(host, time.Now()).rate
+}
@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Rate(t *testing.T) {
+	// 20 requests per second allows a burst of 20, then one every 50ms
+	limiter := NewRateLimiter(20, 0)
+
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		limiter.Wait("example.com", 0)
+	}
+	if elapsed := time.Since(start); elapsed >= 50*time.Millisecond {
+		t.Errorf("expected the burst to go out at once, took %v", elapsed)
+	}
+
+	limiter.Wait("example.com", 0)
+	limiter.Wait("example.com", 0)
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("expected requests past the burst to be spaced out, took %v", elapsed)
+	}
+
+	// Other hosts have their own bucket
+	other := time.Now()
+	limiter.Wait("other.com", 0)
+	if elapsed := time.Since(other); elapsed >= 50*time.Millisecond {
+		t.Errorf("expected a fresh host not to wait, took %v", elapsed)
+	}
+}
+
+func TestRateLimiter_Delay(t *testing.T) {
+	delay := 30 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		limiter *RateLimiter
+		minGap  time.Duration
+	}{
+		{"limiter delay", NewRateLimiter(0, delay), 0},
+		{"per call gap", NewRateLimiter(0, 0), delay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			tt.limiter.Wait("example.com", tt.minGap)
+			tt.limiter.Wait("other.com", tt.minGap)
+			if elapsed := time.Since(start); elapsed >= delay {
+				t.Errorf("expected first requests to different hosts not to wait, took %v", elapsed)
+			}
+
+			tt.limiter.Wait("example.com", tt.minGap)
+			if elapsed := time.Since(start); elapsed < delay {
+				t.Errorf("expected second request to the same host to wait %v, took %v", delay, elapsed)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_Throttle(t *testing.T) {
+	limiter := NewRateLimiter(0, 0)
+	if rate := limiter.Rate("example.com"); rate != 0 {
+		t.Fatalf("expected no limit before a 429, got %v", rate)
+	}
+
+	limiter.Throttle("example.com", 0)
+	if rate := limiter.Rate("example.com"); rate != adaptiveRate {
+		t.Errorf("expected an unlimited host to drop to %v, got %v", adaptiveRate, rate)
+	}
+	limiter.Throttle("example.com", 0)
+	if rate := limiter.Rate("example.com"); rate != adaptiveRate/2 {
+		t.Errorf("expected the rate to halve, got %v", rate)
+	}
+	for i := 0; i < 20; i++ {
+		limiter.Throttle("example.com", 0)
+	}
+	if rate := limiter.Rate("example.com"); rate != minAdaptiveRate {
+		t.Errorf("expected the rate to stop at %v, got %v", minAdaptiveRate, rate)
+	}
+	if rate := limiter.Rate("other.com"); rate != 0 {
+		t.Errorf("expected other hosts to be unaffected, got %v", rate)
+	}
+
+	// Retry-After pauses the host
+	paused := NewRateLimiter(0, 0)
+	paused.Throttle("example.com", 40*time.Millisecond)
+	start := time.Now()
+	paused.Wait("example.com", 0)
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("expected to wait out Retry-After, took %v", elapsed)
+	}
+}
+
+func TestRateLimiter_Nil(t *testing.T) {
+	var limiter *RateLimiter
+	limiter.Wait("example.com", time.Hour)
+	limiter.Throttle("example.com", time.Hour)
+	if rate := limiter.Rate("example.com"); rate != 0 {
+		t.Errorf("expected a nil limiter to have no rate, got %v", rate)
+	}
+}
+
+func TestCheckLinks_RateLimited(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	urls := []string{server.URL + "/a", server.URL + "/b", server.URL + "/c"}
+	limiter := NewRateLimiter(0, 0)
+	opts := CheckOptions{
+		Concurrency: 3,
+		PerHost:     3,
+		Retry:       RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond},
+		Limiter:     limiter,
+	}
+
+	results := CheckLinks(&urls, opts)
+	for _, result := range results {
+		if result.Dead {
+			t.Errorf("expected %s to pass after the 429, got %+v", result.URL, result)
+		}
+	}
+	// The 429 slowed the host down for every later request
+	if rate := limiter.Rate(hostOf(server.URL)); rate != adaptiveRate {
+		t.Errorf("expected the host rate to adapt to %v, got %v", adaptiveRate, rate)
+	}
+}
+
+func TestCheckLinks_LongRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/limited" {
+			w.Header().Set("Retry-After", "86400")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	urls := []string{server.URL + "/limited", server.URL + "/other"}
+	opts := CheckOptions{
+		Concurrency: 1,
+		PerHost:     1,
+		Retry:       RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: 50 * time.Millisecond},
+		Limiter:     NewRateLimiter(0, 0),
+	}
+
+	// A Retry-After past MaxDelay must not hold up the rest of the host
+	start := time.Now()
+	results := CheckLinks(&urls, opts)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the pause to be capped at MaxDelay, took %v", elapsed)
+	}
+	if !results[0].Dead || results[1].Dead {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
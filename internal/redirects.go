@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"net/http"
+	neturl "net/url"
+)
+
+// RedirectHop is one redirect in a chain: the url requested and the
+// status it answered with
+type RedirectHop struct {
+	URL        string
+	StatusCode int
+}
+
+// Redirect issues, for links that work but should still be edited
+const (
+	RedirectPermanent = "permanent"  // moved for good; the link should point at UpdateTo
+	RedirectLongChain = "long_chain" // goes through more redirects than the configured limit
+	RedirectHomepage  = "homepage"   // a page redirecting to the site root, usually a removed page
+)
+
+// redirectChain returns the redirects that led to resp, oldest first
+func redirectChain(resp *http.Response) []RedirectHop {
+	var hops []RedirectHop
+	for previous := resp.Request.Response; previous != nil; previous = previous.Request.Response {
+		hops = append([]RedirectHop{{URL: previous.Request.URL.String(), StatusCode: previous.StatusCode}}, hops...)
+	}
+	return hops
+}
+
+// permanentRedirect reports whether a status moves a resource for good
+func permanentRedirect(statusCode int) bool {
+	return statusCode == http.StatusMovedPermanently || statusCode == http.StatusPermanentRedirect
+}
+
+// UpdateTo returns the url a link should be changed to: where the chain
+// of permanent redirects starting at the link ends. It is "" when the
+// first redirect is temporary, since the link itself is still correct.
+func (r LinkResult) UpdateTo() string {
+	target := ""
+	for i, hop := range r.Redirects {
+		if !permanentRedirect(hop.StatusCode) {
+			break
+		}
+		if i+1 < len(r.Redirects) {
+			target = r.Redirects[i+1].URL
+		} else {
+			target = r.FinalURL
+		}
+	}
+	return target
+}
+
+// checkRedirects records the redirect issues of a link that works
+func checkRedirects(result *LinkResult, longChain int) {
+	if result.Dead || len(result.Redirects) == 0 {
+		return
+	}
+	if result.UpdateTo() != "" {
+		result.RedirectIssues = append(result.RedirectIssues, RedirectPermanent)
+	}
+	if longChain > 0 && len(result.Redirects) > longChain {
+		result.RedirectIssues = append(result.RedirectIssues, RedirectLongChain)
+	}
+	if redirectsHome(result.URL, result.FinalURL) {
+		result.RedirectIssues = append(result.RedirectIssues, RedirectHomepage)
+	}
+}
+
+// redirectsHome reports whether a deeper page ended up at the root of a site
+func redirectsHome(from, to string) bool {
+	fromURL, err := neturl.Parse(from)
+	if err != nil {
+		return false
+	}
+	toURL, err := neturl.Parse(to)
+	if err != nil {
+		return false
+	}
+	isRoot := func(path string) bool { return path == "" || path == "/" }
+	return !isRoot(fromURL.Path) && isRoot(toURL.Path) && toURL.RawQuery == ""
+}
+
+// RedirectResults filters results down to the ones with redirect issues
+func RedirectResults(results []LinkResult) []LinkResult {
+	var redirected []LinkResult
+	for _, result := range results {
+		if len(result.RedirectIssues) > 0 {
+			redirected = append(redirected, result)
+		}
+	}
+	return redirected
+}
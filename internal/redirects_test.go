@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newRedirectServer() *httptest.Server {
+	redirects := map[string]struct {
+		to     string
+		status int
+	}{
+		"/temporary": {"/moved", http.StatusFound},
+		"/moved":     {"/final", http.StatusMovedPermanently},
+		"/old":       {"/older", http.StatusMovedPermanently},
+		"/older":     {"/final", http.StatusPermanentRedirect},
+		"/mixed":     {"/step", http.StatusMovedPermanently},
+		"/step":      {"/final", http.StatusTemporaryRedirect},
+		"/loop-a":    {"/loop-b", http.StatusFound},
+		"/loop-b":    {"/loop-a", http.StatusFound},
+		"/removed":   {"/", http.StatusMovedPermanently},
+		"/c1":        {"/c2", http.StatusFound},
+		"/c2":        {"/c3", http.StatusFound},
+		"/c3":        {"/c4", http.StatusFound},
+		"/c4":        {"/final", http.StatusFound},
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if redirect, ok := redirects[r.URL.Path]; ok {
+			http.Redirect(w, r, redirect.to, redirect.status)
+		}
+	}))
+}
+
+func TestCheckLinks_Redirects(t *testing.T) {
+	server := newRedirectServer()
+	defer server.Close()
+
+	paths := []string{"/final", "/temporary", "/old", "/mixed", "/removed", "/c1", "/loop-a"}
+	var urls []string
+	for _, path := range paths {
+		urls = append(urls, server.URL+path)
+	}
+	results := CheckLinks(&urls, CheckOptions{Concurrency: 4, PerHost: 4, LongChain: 3})
+	byPath := make(map[string]LinkResult)
+	for i, path := range paths {
+		byPath[path] = results[i]
+	}
+
+	tests := []struct {
+		path     string
+		hops     []int
+		issues   string
+		updateTo string
+	}{
+		{"/final", nil, "", ""},
+		// The link itself is fine; only the page it lands on moved
+		{"/temporary", []int{302, 301}, "", ""},
+		{"/old", []int{301, 308}, "permanent", "/final"},
+		{"/mixed", []int{301, 307}, "permanent", "/step"},
+		{"/removed", []int{301}, "permanent,homepage", "/"},
+		{"/c1", []int{302, 302, 302, 302}, "long_chain", ""},
+	}
+	for _, tt := range tests {
+		result := byPath[tt.path]
+		var hops []int
+		for _, hop := range result.Redirects {
+			hops = append(hops, hop.StatusCode)
+		}
+		if result.Dead || len(hops) != len(tt.hops) {
+			t.Errorf("%s: expected an alive link with hops %v, got %+v", tt.path, tt.hops, result)
+			continue
+		}
+		for i := range hops {
+			if hops[i] != tt.hops[i] {
+				t.Errorf("%s: expected hops %v, got %v", tt.path, tt.hops, hops)
+				break
+			}
+		}
+		if got := strings.Join(result.RedirectIssues, ","); got != tt.issues {
+			t.Errorf("%s: issues = %q, expected %q", tt.path, got, tt.issues)
+		}
+		expectedUpdate := ""
+		if tt.updateTo != "" {
+			expectedUpdate = server.URL + tt.updateTo
+		}
+		if result.UpdateTo() != expectedUpdate {
+			t.Errorf("%s: UpdateTo() = %q, expected %q", tt.path, result.UpdateTo(), expectedUpdate)
+		}
+	}
+
+	// The first hop is the link itself
+	if old := byPath["/old"]; old.Redirects[0].URL != server.URL+"/old" || old.Redirects[1].URL != server.URL+"/older" {
+		t.Errorf("unexpected chain %+v", old.Redirects)
+	}
+
+	loop := byPath["/loop-a"]
+	if !loop.Dead || loop.Category != CategoryRedirect || !strings.Contains(loop.Error, "redirect loop") {
+		t.Errorf("expected a dead redirect loop, got %+v", loop)
+	}
+	if len(loop.Redirects) != 2 || loop.FinalURL != server.URL+"/loop-a" {
+		t.Errorf("expected the loop's chain back to the start, got %+v", loop)
+	}
+}
+
+func TestClient_RedirectLoop(t *testing.T) {
+	server := newRedirectServer()
+	defer server.Close()
+
+	if _, err := defaultClient.Scrape(server.URL + "/loop-a"); !errors.Is(err, ErrRedirectLoop) {
+		t.Errorf("expected ErrRedirectLoop, got %v", err)
+	}
+}
+
+func TestNewReportLink_Redirects(t *testing.T) {
+	result := LinkResult{
+		URL:            "https://example.com/old",
+		FinalURL:       "https://example.com/new",
+		Redirected:     true,
+		Redirects:      []RedirectHop{{URL: "https://example.com/old", StatusCode: 301}},
+		RedirectIssues: []string{RedirectPermanent},
+	}
+	link := NewReportLink(result, nil, false)
+	if len(link.Redirects) != 1 || link.Redirects[0].StatusCode != 301 || link.UpdateTo != "https://example.com/new" {
+		t.Errorf("unexpected report link %+v", link)
+	}
+
+	plain := NewReportLink(LinkResult{URL: "https://example.com/"}, nil, false)
+	if plain.Redirects == nil || len(plain.Redirects) != 0 || plain.UpdateTo != "" {
+		t.Errorf("expected an empty chain, got %+v", plain)
+	}
+}
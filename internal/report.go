@@ -0,0 +1,313 @@
+package internal
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+)
+
+// DeadLink pairs a failed check with one place the link appears
+type DeadLink struct {
+	Result LinkResult
+	Source LinkSource
+}
+
+// PageReport lists the dead links found on a single page
+type PageReport struct {
+	Page  string
+	Links []DeadLink
+}
+
+// GroupByPage arranges dead results under the pages that link to them,
+// so each entry is a page that needs fixing. Pages are sorted by url and
+// links within a page by their position in the source.
+func GroupByPage(dead []LinkResult, sources map[string][]LinkSource) []PageReport {
+	byPage := make(map[string][]DeadLink)
+
+	for _, result := range dead {
+		refs := sources[result.URL]
+		// Links with no known source still get reported
+		if len(refs) == 0 {
+			refs = []LinkSource{{}}
+		}
+		for _, ref := range refs {
+			byPage[ref.Page] = append(byPage[ref.Page], DeadLink{Result: result, Source: ref})
+		}
+	}
+
+	var reports []PageReport
+	for page, links := range byPage {
+		sort.SliceStable(links, func(i, j int) bool {
+			if links[i].Source.Line != links[j].Source.Line {
+				return links[i].Source.Line < links[j].Source.Line
+			}
+			return links[i].Source.Column < links[j].Source.Column
+		})
+		reports = append(reports, PageReport{Page: page, Links: links})
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Page < reports[j].Page
+	})
+	return reports
+}
+
+// SitemapProblems returns the results for sitemap entries that are dead or
+// redirect elsewhere, since both should be fixed in the sitemap.
+func SitemapProblems(results []LinkResult, entries []string) []LinkResult {
+	listed := make(map[string]bool)
+	for _, entry := range entries {
+		listed[entry] = true
+	}
+
+	var problems []LinkResult
+	for _, result := range results {
+		if listed[result.URL] && (result.Dead || result.Redirected) {
+			problems = append(problems, result)
+		}
+	}
+	return problems
+}
+
+// ReportSchemaVersion identifies the layout of the JSON and NDJSON output.
+// It only changes when a field is removed or changes meaning; new fields
+// may be added without a bump.
+const ReportSchemaVersion = 1
+
+// Report is the machine-readable result of a whole check
+type Report struct {
+	SchemaVersion int           `json:"schema_version"`
+	StartURL      string        `json:"start_url"`
+	StartError    string        `json:"start_error,omitempty"`
+	Summary       ReportSummary `json:"summary"`
+	Links         []ReportLink  `json:"links"`
+	RobotsBlocked []string      `json:"robots_blocked"`
+	SitemapErrors []string      `json:"sitemap_errors"`
+}
+
+// ReportSummary counts the outcomes of a check
+type ReportSummary struct {
+	Checked         int `json:"checked"`
+	Alive           int `json:"alive"`
+	Dead            int `json:"dead"`
+	Redirected      int `json:"redirected"`
+	Flaky           int `json:"flaky"`
+	Ignored         int `json:"ignored"`
+	RedirectIssues  int `json:"redirect_issues"`
+	Soft404         int `json:"suspected_soft_404"`
+	Cached          int `json:"cached"`
+	RobotsBlocked   int `json:"robots_blocked"`
+	SitemapEntries  int `json:"sitemap_entries"`
+	SitemapProblems int `json:"sitemap_problems"`
+}
+
+// ReportLink is one checked link with everywhere it was found
+type ReportLink struct {
+	URL        string         `json:"url"`
+	Status     string         `json:"status"` // "alive", "dead" or "ignored"
+	StatusCode int            `json:"status_code,omitempty"`
+	Category   ErrorCategory  `json:"error_category,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	FinalURL   string         `json:"final_url,omitempty"`
+	Redirected bool           `json:"redirected"`
+	LatencyMS  int64          `json:"latency_ms"`
+	Method     string         `json:"method"`
+	Attempts   int            `json:"attempts"`
+	Cached     bool           `json:"cached"`
+	InSitemap  bool           `json:"in_sitemap"`
+	Sources    []ReportSource `json:"sources"`
+
+	Redirects      []ReportRedirect `json:"redirects"`
+	RedirectIssues []string         `json:"redirect_issues,omitempty"`
+	UpdateTo       string           `json:"update_to,omitempty"` // where a permanently moved link should point
+	Soft404        *ReportSoft404   `json:"soft_404,omitempty"`
+}
+
+// ReportSoft404 explains why an alive link looks like an error page
+type ReportSoft404 struct {
+	Confidence float64 `json:"confidence"`
+	Reason     string  `json:"reason"`
+}
+
+// ReportRedirect is one hop of a redirect chain
+type ReportRedirect struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+}
+
+// ReportSource is one place a link appears
+type ReportSource struct {
+	Page      string `json:"page"`
+	Original  string `json:"original"` // the link as written in the page
+	Element   string `json:"element"`
+	Attribute string `json:"attribute"`
+	Kind      string `json:"kind"`
+	Text      string `json:"text"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+}
+
+// NewReportLink converts a check result and its sources to report form
+func NewReportLink(result LinkResult, sources []LinkSource, inSitemap bool) ReportLink {
+	link := ReportLink{
+		URL:        result.URL,
+		Status:     "alive",
+		StatusCode: result.StatusCode,
+		Category:   result.Category,
+		Error:      result.Error,
+		FinalURL:   result.FinalURL,
+		Redirected: result.Redirected,
+		LatencyMS:  result.Latency.Milliseconds(),
+		Method:     result.Method,
+		Attempts:   result.Attempts,
+		Cached:     result.Cached,
+		InSitemap:  inSitemap,
+		Sources:    []ReportSource{},
+
+		Redirects:      []ReportRedirect{},
+		RedirectIssues: result.RedirectIssues,
+		UpdateTo:       result.UpdateTo(),
+	}
+	if result.Soft404 > 0 {
+		link.Soft404 = &ReportSoft404{Confidence: result.Soft404, Reason: result.Soft404Reason}
+	}
+	for _, hop := range result.Redirects {
+		link.Redirects = append(link.Redirects, ReportRedirect{URL: hop.URL, StatusCode: hop.StatusCode})
+	}
+	if result.Dead {
+		link.Status = "dead"
+	} else if result.Ignored {
+		link.Status = "ignored"
+	}
+	for _, source := range sources {
+		link.Sources = append(link.Sources, ReportSource{
+			Page:      source.Page,
+			Original:  source.Original,
+			Element:   source.Element,
+			Attribute: source.Attribute,
+			Kind:      source.Kind,
+			Text:      source.Text,
+			Line:      source.Line,
+			Column:    source.Column,
+		})
+	}
+	return link
+}
+
+// Summarize counts the outcomes of a check
+func Summarize(crawl *CrawlResult, results []LinkResult) ReportSummary {
+	summary := ReportSummary{
+		Checked:         len(results),
+		RobotsBlocked:   len(crawl.RobotsBlocked),
+		SitemapEntries:  len(crawl.SitemapEntries),
+		SitemapProblems: len(SitemapProblems(results, crawl.SitemapEntries)),
+	}
+	for _, result := range results {
+		switch {
+		case result.Dead:
+			summary.Dead++
+		case result.Ignored:
+			summary.Ignored++
+		default:
+			summary.Alive++
+		}
+		if result.Redirected {
+			summary.Redirected++
+		}
+		if result.Flaky() {
+			summary.Flaky++
+		}
+		if len(result.RedirectIssues) > 0 {
+			summary.RedirectIssues++
+		}
+		if result.Soft404 > 0 {
+			summary.Soft404++
+		}
+		if result.Cached {
+			summary.Cached++
+		}
+	}
+	return summary
+}
+
+// BuildReport assembles the full report for a crawl and its check results
+func BuildReport(startURL string, crawl *CrawlResult, results []LinkResult) *Report {
+	inSitemap := crawl.SitemapSet()
+
+	report := &Report{
+		SchemaVersion: ReportSchemaVersion,
+		StartURL:      startURL,
+		StartError:    crawl.StartError,
+		Summary:       Summarize(crawl, results),
+		Links:         []ReportLink{},
+		RobotsBlocked: append([]string{}, crawl.RobotsBlocked...),
+		SitemapErrors: append([]string{}, crawl.SitemapErrors...),
+	}
+	for _, result := range results {
+		report.Links = append(report.Links, NewReportLink(result, crawl.Sources[result.URL], inSitemap[result.URL]))
+	}
+	return report
+}
+
+// WriteJSON writes the report as a single indented JSON document
+func WriteJSON(w io.Writer, report *Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// NDJSON event types
+const (
+	EventLink    = "link"
+	EventSkipped = "skipped"
+	EventSummary = "summary"
+)
+
+// ndjsonEvent is one line of NDJSON output. The payload is held in the
+// field named after the event type; the others are omitted.
+type ndjsonEvent struct {
+	SchemaVersion int            `json:"schema_version"`
+	Type          string         `json:"type"`
+	Link          *ReportLink    `json:"link,omitempty"`
+	Skipped       *skippedEvent  `json:"skipped,omitempty"`
+	Summary       *ReportSummary `json:"summary,omitempty"`
+}
+
+// skippedEvent describes a link that was not checked
+type skippedEvent struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// NDJSONWriter streams one JSON event per line. It is safe to call from
+// several goroutines at once.
+type NDJSONWriter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{encoder: json.NewEncoder(w)}
+}
+
+func (n *NDJSONWriter) write(event ndjsonEvent) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	event.SchemaVersion = ReportSchemaVersion
+	return n.encoder.Encode(event)
+}
+
+// WriteLink emits a "link" event for one checked link
+func (n *NDJSONWriter) WriteLink(link ReportLink) error {
+	return n.write(ndjsonEvent{Type: EventLink, Link: &link})
+}
+
+// WriteSkipped emits a "skipped" event for a link that was not checked
+func (n *NDJSONWriter) WriteSkipped(url, reason string) error {
+	return n.write(ndjsonEvent{Type: EventSkipped, Skipped: &skippedEvent{URL: url, Reason: reason}})
+}
+
+// WriteSummary emits the final "summary" event
+func (n *NDJSONWriter) WriteSummary(summary ReportSummary) error {
+	return n.write(ndjsonEvent{Type: EventSummary, Summary: &summary})
+}
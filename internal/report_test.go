@@ -0,0 +1,198 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGroupByPage(t *testing.T) {
+	dead := []LinkResult{
+		{URL: "https://example.com/gone", StatusCode: 404, Dead: true, Category: CategoryHTTP},
+		{URL: "https://other.com/missing", StatusCode: 500, Dead: true, Category: CategoryHTTP},
+		{URL: "https://orphan.com", Dead: true, Category: CategoryDNS},
+	}
+	sources := map[string][]LinkSource{
+		"https://example.com/gone": {
+			{Page: "https://example.com/b", Element: "a", Attribute: "href", Text: "Gone", Line: 3, Column: 1},
+			{Page: "https://example.com/a", Element: "a", Attribute: "href", Text: "Gone", Line: 9, Column: 2},
+		},
+		"https://other.com/missing": {
+			{Page: "https://example.com/a", Element: "a", Attribute: "href", Text: "Missing", Line: 2, Column: 5},
+		},
+	}
+
+	reports := GroupByPage(dead, sources)
+
+	if len(reports) != 3 {
+		t.Fatalf("expected 3 page reports, got %d: %+v", len(reports), reports)
+	}
+
+	// The orphan has no source page so it sorts first under ""
+	if reports[0].Page != "" || len(reports[0].Links) != 1 || reports[0].Links[0].Result.URL != "https://orphan.com" {
+		t.Errorf("expected orphan link under empty page, got %+v", reports[0])
+	}
+
+	pageA := reports[1]
+	if pageA.Page != "https://example.com/a" || len(pageA.Links) != 2 {
+		t.Fatalf("expected two dead links on page a, got %+v", pageA)
+	}
+	// Sorted by line within the page
+	if pageA.Links[0].Result.URL != "https://other.com/missing" || pageA.Links[1].Result.URL != "https://example.com/gone" {
+		t.Errorf("expected links sorted by line, got %+v", pageA.Links)
+	}
+	if pageA.Links[1].Source.Text != "Gone" || pageA.Links[1].Source.Line != 9 {
+		t.Errorf("expected source details to be kept, got %+v", pageA.Links[1].Source)
+	}
+
+	if reports[2].Page != "https://example.com/b" || len(reports[2].Links) != 1 {
+		t.Errorf("expected one dead link on page b, got %+v", reports[2])
+	}
+}
+
+func TestGroupByPage_Empty(t *testing.T) {
+	if reports := GroupByPage(nil, nil); len(reports) != 0 {
+		t.Errorf("expected no reports, got %+v", reports)
+	}
+}
+
+func TestSitemapProblems(t *testing.T) {
+	results := []LinkResult{
+		{URL: "https://example.com/ok", StatusCode: 200},
+		{URL: "https://example.com/moved", StatusCode: 200, Redirected: true, FinalURL: "https://example.com/new"},
+		{URL: "https://example.com/gone", StatusCode: 404, Dead: true},
+		{URL: "https://example.com/linked-gone", StatusCode: 404, Dead: true},
+	}
+	entries := []string{"https://example.com/ok", "https://example.com/moved", "https://example.com/gone"}
+
+	problems := SitemapProblems(results, entries)
+	if len(problems) != 2 || problems[0].URL != "https://example.com/moved" || problems[1].URL != "https://example.com/gone" {
+		t.Errorf("expected the moved and gone entries, got %+v", problems)
+	}
+}
+
+func testCrawlAndResults() (*CrawlResult, []LinkResult) {
+	crawl := newCrawlResult()
+	crawl.Links = []string{"https://example.com/ok", "https://example.com/gone"}
+	crawl.Sources["https://example.com/ok"] = []LinkSource{
+		{Page: "https://example.com/", Element: "a", Attribute: "href", Kind: KindAnchor, Text: "OK", Line: 4, Column: 2},
+	}
+	crawl.Sources["https://example.com/gone"] = []LinkSource{
+		{Page: "https://example.com/sitemap.xml", Element: "sitemap", Attribute: "loc", Kind: KindSitemap, Line: 3, Column: 10},
+	}
+	crawl.SitemapEntries = []string{"https://example.com/gone"}
+	crawl.RobotsBlocked = []string{"https://example.com/private"}
+
+	results := []LinkResult{
+		{URL: "https://example.com/ok", StatusCode: 200, FinalURL: "https://example.com/ok/", Redirected: true, Latency: 120 * time.Millisecond, Method: "HEAD", Attempts: 2},
+		{URL: "https://example.com/gone", StatusCode: 404, Dead: true, Category: CategoryHTTP, Error: "Not Found", FinalURL: "https://example.com/gone"},
+	}
+	return crawl, results
+}
+
+func TestBuildReport_JSON(t *testing.T) {
+	crawl, results := testCrawlAndResults()
+	report := BuildReport("https://example.com/", crawl, results)
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, report); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	// Decode generically to check the documented field names
+	var decoded map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if decoded["schema_version"] != float64(ReportSchemaVersion) || decoded["start_url"] != "https://example.com/" {
+		t.Errorf("unexpected header fields: %v", decoded)
+	}
+	if _, present := decoded["start_error"]; present {
+		t.Errorf("expected start_error to be omitted when the start page loaded: %v", decoded)
+	}
+
+	summary := decoded["summary"].(map[string]any)
+	expectedSummary := map[string]float64{
+		"checked": 2, "alive": 1, "dead": 1, "redirected": 1, "flaky": 1,
+		"robots_blocked": 1, "sitemap_entries": 1, "sitemap_problems": 1,
+	}
+	for key, value := range expectedSummary {
+		if summary[key] != value {
+			t.Errorf("summary[%q] = %v, expected %v", key, summary[key], value)
+		}
+	}
+
+	links := decoded["links"].([]any)
+	if len(links) != 2 {
+		t.Fatalf("expected 2 links, got %d", len(links))
+	}
+	ok := links[0].(map[string]any)
+	if ok["status"] != "alive" || ok["latency_ms"] != float64(120) || ok["redirected"] != true || ok["final_url"] != "https://example.com/ok/" || ok["method"] != "HEAD" || ok["attempts"] != float64(2) {
+		t.Errorf("unexpected alive link: %v", ok)
+	}
+	if _, present := ok["error_category"]; present {
+		t.Errorf("expected error_category to be omitted for alive links: %v", ok)
+	}
+	source := ok["sources"].([]any)[0].(map[string]any)
+	if source["page"] != "https://example.com/" || source["kind"] != "anchor" || source["line"] != float64(4) {
+		t.Errorf("unexpected source: %v", source)
+	}
+
+	gone := links[1].(map[string]any)
+	if gone["status"] != "dead" || gone["error_category"] != "http" || gone["status_code"] != float64(404) || gone["in_sitemap"] != true {
+		t.Errorf("unexpected dead link: %v", gone)
+	}
+
+	if blocked := decoded["robots_blocked"].([]any); len(blocked) != 1 {
+		t.Errorf("expected one robots blocked url, got %v", blocked)
+	}
+	if errs := decoded["sitemap_errors"].([]any); len(errs) != 0 {
+		t.Errorf("expected empty sitemap errors array, got %v", errs)
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	crawl, results := testCrawlAndResults()
+
+	var buf bytes.Buffer
+	writer := NewNDJSONWriter(&buf)
+	writer.WriteSkipped("https://example.com/private", "robots")
+
+	// Concurrent writes must not interleave lines
+	var wg sync.WaitGroup
+	for _, result := range results {
+		wg.Add(1)
+		go func(result LinkResult) {
+			defer wg.Done()
+			writer.WriteLink(NewReportLink(result, crawl.Sources[result.URL], false))
+		}(result)
+	}
+	wg.Wait()
+	writer.WriteSummary(Summarize(crawl, results))
+
+	var types []string
+	scanner := bufio.NewScanner(strings.NewReader(buf.String()))
+	for scanner.Scan() {
+		var event map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+		}
+		if event["schema_version"] != float64(ReportSchemaVersion) {
+			t.Errorf("expected schema version on every event: %v", event)
+		}
+		eventType := event["type"].(string)
+		types = append(types, eventType)
+		if _, ok := event[eventType]; !ok {
+			t.Errorf("expected payload under %q: %v", eventType, event)
+		}
+	}
+
+	expected := []string{EventSkipped, EventLink, EventLink, EventSummary}
+	if strings.Join(types, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected events %v, got %v", expected, types)
+	}
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how links that fail transiently are retried
+type RetryPolicy struct {
+	MaxRetries int           // extra attempts after the first; 0 disables retries
+	BaseDelay  time.Duration // wait before the first retry, doubled for each one after
+	MaxDelay   time.Duration // longest wait between attempts, including Retry-After
+}
+
+// DefaultRetryPolicy returns the policy used when none is given
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 2,
+		BaseDelay:  time.Second,
+		MaxDelay:   30 * time.Second,
+	}
+}
+
+// retryStatuses are responses that usually clear up on their own
+var retryStatuses = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// retryable reports whether a failed result is worth another attempt
+func retryable(result LinkResult) bool {
+	if result.Category == CategoryTimeout || result.Category == CategoryNetwork {
+		return true
+	}
+	return retryStatuses[result.StatusCode]
+}
+
+// backoff returns the jittered wait before the given retry, counting from 0.
+// Half the delay is fixed and half random, so retries from many workers
+// spread out instead of arriving together.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 0; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// delay returns how long to wait before the given retry. A Retry-After
+// from the server wins over the backoff; if it asks for longer than
+// MaxDelay the link is not retried at all.
+func (p RetryPolicy) delay(retry int, retryAfter time.Duration) (time.Duration, bool) {
+	if retryAfter > 0 {
+		if p.MaxDelay > 0 && retryAfter > p.MaxDelay {
+			return 0, false
+		}
+		return retryAfter, true
+	}
+	return p.backoff(retry), true
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as
+// an HTTP date. It returns 0 when the header is missing or unusable.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// checkWithRetry checks a url, retrying transient failures under the retry
+// policy. Every attempt waits its turn with the rate limiter, and a 429
+// slows the limiter down for the host, pausing it for no longer than
+// MaxDelay however long the server asks. Validators are sent with every
+// attempt.
+func checkWithRetry(url string, validators http.Header, opts CheckOptions) LinkResult {
+	policy := opts.Retry
+	host := hostOf(url)
+	for retry := 0; ; retry++ {
+		opts.Limiter.Wait(host, 0)
+		result, retryAfter := checkLink(opts.Client, url, opts.Method, validators)
+		result.Attempts = retry + 1
+		if result.StatusCode == http.StatusTooManyRequests {
+			pause := retryAfter
+			if policy.MaxDelay > 0 && pause > policy.MaxDelay {
+				pause = policy.MaxDelay
+			}
+			opts.Limiter.Throttle(host, pause)
+		}
+		if !result.Dead || !retryable(result) || retry >= policy.MaxRetries {
+			return result
+		}
+		wait, ok := policy.delay(retry, retryAfter)
+		if !ok {
+			return result
+		}
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{" 0 ", 0},
+		{"-3", 0},
+		{"soon", 0},
+		{"Wed, 01 May 2024 12:00:30 GMT", 30 * time.Second},
+		{"Wed, 01 May 2024 11:59:00 GMT", 0}, // already passed
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.expected {
+			t.Errorf("parseRetryAfter(%q) = %v, expected %v", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	// Each retry waits between half and all of the doubled delay, capped
+	bounds := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for retry, bound := range bounds {
+		bound *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			got := policy.backoff(retry)
+			if got < bound/2 || got > bound {
+				t.Fatalf("backoff(%d) = %v, expected between %v and %v", retry, got, bound/2, bound)
+			}
+		}
+	}
+
+	if got := (RetryPolicy{}).backoff(3); got != 0 {
+		t.Errorf("expected no wait without a base delay, got %v", got)
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Second}
+
+	if wait, ok := policy.delay(0, 3*time.Second); !ok || wait != 3*time.Second {
+		t.Errorf("expected Retry-After to be honoured, got %v %v", wait, ok)
+	}
+	if _, ok := policy.delay(0, time.Minute); ok {
+		t.Errorf("expected no retry when Retry-After exceeds MaxDelay")
+	}
+	if wait, ok := policy.delay(1, 0); !ok || wait > 2*time.Millisecond {
+		t.Errorf("expected backoff without Retry-After, got %v %v", wait, ok)
+	}
+}
+
+func TestCheckLinks_Retry(t *testing.T) {
+	// Counts requests per path
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		hit := hits[r.URL.Path]
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/recovers":
+			if hit < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		case "/throttled":
+			if hit == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+			}
+		case "/down":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/closed-for-hours":
+			w.Header().Set("Retry-After", "7200")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/gone":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path     string
+		dead     bool
+		attempts int
+	}{
+		{"/ok", false, 1},
+		{"/recovers", false, 3},
+		{"/throttled", false, 2},
+		{"/down", true, 3},
+		{"/closed-for-hours", true, 1},
+		{"/gone", true, 1},
+	}
+
+	var urls []string
+	for _, tt := range tests {
+		urls = append(urls, server.URL+tt.path)
+	}
+	opts := CheckOptions{
+		Concurrency: 4,
+		PerHost:     4,
+		Retry:       RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Second},
+	}
+	results := CheckLinks(&urls, opts)
+
+	for i, tt := range tests {
+		result := results[i]
+		if result.Dead != tt.dead || result.Attempts != tt.attempts {
+			t.Errorf("%s: expected dead=%v attempts=%d, got %+v", tt.path, tt.dead, tt.attempts, result)
+		}
+	}
+
+	flaky := FlakyResults(results)
+	if len(flaky) != 2 || flaky[0].URL != server.URL+"/recovers" || flaky[1].URL != server.URL+"/throttled" {
+		t.Errorf("expected the recovering links to be flaky, got %+v", flaky)
+	}
+}
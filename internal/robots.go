@@ -0,0 +1,299 @@
+package internal
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Robots holds the robots.txt rules that apply to one user agent on one host
+type Robots struct {
+	rules      []robotsRule
+	CrawlDelay time.Duration
+	Sitemaps   []string // Sitemap lines, which apply to every agent
+}
+
+// robotsRule is a single Allow or Disallow line
+type robotsRule struct {
+	allow   bool
+	length  int // pattern length, longer patterns win
+	pattern *regexp.Regexp
+}
+
+// robotsGroup is a run of user-agent lines and the rules that follow them
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// ParseRobots reads a robots.txt file and keeps the rules for userAgent.
+// Groups naming the agent take priority; otherwise the "*" groups apply.
+func ParseRobots(content, userAgent string) *Robots {
+	var groups []*robotsGroup
+	var current *robotsGroup
+	var sitemaps []string
+	// A user-agent line after rules starts a new group
+	inRules := false
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Strip comments
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if current == nil || inRules {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+				inRules = false
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if current == nil {
+				continue
+			}
+			inRules = true
+			// An empty Disallow allows everything
+			if value == "" {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{
+				allow:   key == "allow",
+				length:  len(value),
+				pattern: compileRobotsPattern(value),
+			})
+		case "sitemap":
+			// Sitemap lines sit outside groups and do not end one
+			if value != "" {
+				sitemaps = append(sitemaps, value)
+			}
+		case "crawl-delay":
+			if current == nil {
+				continue
+			}
+			inRules = true
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+
+	robots := &Robots{Sitemaps: sitemaps}
+	agent := strings.ToLower(userAgent)
+	// Try our own token first, then fall back to the wildcard
+	for _, want := range []string{agent, "*"} {
+		matched := false
+		for _, group := range groups {
+			for _, name := range group.agents {
+				if name == want {
+					matched = true
+					robots.rules = append(robots.rules, group.rules...)
+					if group.crawlDelay > robots.CrawlDelay {
+						robots.CrawlDelay = group.crawlDelay
+					}
+					break
+				}
+			}
+		}
+		if matched {
+			break
+		}
+	}
+	return robots
+}
+
+// compileRobotsPattern turns a robots path pattern into a regexp, where
+// "*" matches any run of characters and a trailing "$" anchors the end.
+func compileRobotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// Allowed reports whether the path (with any query string) may be fetched.
+// The longest matching rule wins and Allow beats Disallow on a tie.
+func (r *Robots) Allowed(path string) bool {
+	if r == nil {
+		return true
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	allowed := true
+	best := -1
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > best || (rule.length == best && rule.allow) {
+			best = rule.length
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+// crawlDelay returns the Crawl-delay, treating missing rules as no delay
+func (r *Robots) crawlDelay() time.Duration {
+	if r == nil {
+		return 0
+	}
+	return r.CrawlDelay
+}
+
+// disallowAll is used when robots.txt exists but the server is failing
+var disallowAll = &Robots{
+	rules: []robotsRule{{allow: false, length: 1, pattern: regexp.MustCompile("^/")}},
+}
+
+// robotsCache fetches robots.txt once per host and remembers the rules
+type robotsCache struct {
+	mu        sync.Mutex
+	userAgent string
+	client    *Client
+	hosts     map[string]*Robots
+}
+
+func newRobotsCache(userAgent string, client *Client) *robotsCache {
+	return &robotsCache{
+		userAgent: userAgent,
+		client:    client,
+		hosts:     make(map[string]*Robots),
+	}
+}
+
+// robotsKey returns scheme://host for a url, or "" for non-http urls
+func robotsKey(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ""
+	}
+	return strings.ToLower(parsed.Scheme + "://" + parsed.Host)
+}
+
+// prefetch loads robots.txt for every host in urls that has not been seen,
+// using up to workers concurrent requests.
+func (c *robotsCache) prefetch(urls []string, workers int) {
+	var missing []string
+	seen := make(map[string]bool)
+
+	c.mu.Lock()
+	for _, rawURL := range urls {
+		key := robotsKey(rawURL)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if _, ok := c.hosts[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	c.mu.Unlock()
+
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range jobs {
+				robots := fetchRobots(c.client, key+"/robots.txt", c.userAgent)
+				c.mu.Lock()
+				c.hosts[key] = robots
+				c.mu.Unlock()
+			}
+		}()
+	}
+	for _, key := range missing {
+		jobs <- key
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// lookup returns the rules for the host of rawURL, fetching them if needed
+func (c *robotsCache) lookup(rawURL string) *Robots {
+	key := robotsKey(rawURL)
+	if key == "" {
+		return nil
+	}
+	c.mu.Lock()
+	robots, ok := c.hosts[key]
+	c.mu.Unlock()
+	if !ok {
+		c.prefetch([]string{rawURL}, 1)
+		c.mu.Lock()
+		robots = c.hosts[key]
+		c.mu.Unlock()
+	}
+	return robots
+}
+
+// allowed reports whether rawURL may be fetched under its host's robots.txt
+func (c *robotsCache) allowed(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return true
+	}
+	return c.lookup(rawURL).Allowed(parsed.RequestURI())
+}
+
+// fetchRobots downloads and parses a robots.txt file. A missing file
+// (4xx) or an unreachable host allows everything; a server error (5xx)
+// disallows everything until the site recovers.
+func fetchRobots(client *Client, robotsURL, userAgent string) *Robots {
+	req, err := http.NewRequest("GET", robotsURL, nil)
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return disallowAll
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	// robots.txt files over 500 KiB are truncated, as crawlers commonly do
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 500*1024))
+	if err != nil {
+		return nil
+	}
+	return ParseRobots(string(body), userAgent)
+}
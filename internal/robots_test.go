@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRobots(t *testing.T) {
+	content := `# Example robots.txt
+User-agent: *
+Disallow: /private/
+Allow: /private/public-page
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: dead-link-checker
+User-agent: otherbot
+Disallow: /no-checkers
+Crawl-delay: 0.5
+
+User-agent: emptybot
+Disallow:
+`
+
+	tests := []struct {
+		name     string
+		agent    string
+		path     string
+		expected bool
+	}{
+		{"wildcard disallowed", "somebot", "/private/page", false},
+		{"wildcard allowed by longer rule", "somebot", "/private/public-page", true},
+		{"wildcard unmatched path", "somebot", "/about", true},
+		{"pattern with end anchor", "somebot", "/files/report.pdf", false},
+		{"end anchor does not match longer path", "somebot", "/files/report.pdf?x=1", true},
+		{"own group replaces wildcard", "dead-link-checker", "/private/page", true},
+		{"own group rule", "dead-link-checker", "/no-checkers/page", false},
+		{"agent match is case insensitive", "Dead-Link-Checker", "/no-checkers", false},
+		{"empty disallow allows everything", "emptybot", "/private/page", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			robots := ParseRobots(content, tt.agent)
+			if got := robots.Allowed(tt.path); got != tt.expected {
+				t.Errorf("Allowed(%q) for %q = %v, expected %v", tt.path, tt.agent, got, tt.expected)
+			}
+		})
+	}
+
+	if delay := ParseRobots(content, "somebot").CrawlDelay; delay != 2*time.Second {
+		t.Errorf("expected wildcard crawl delay of 2s, got %v", delay)
+	}
+	if delay := ParseRobots(content, "dead-link-checker").CrawlDelay; delay != 500*time.Millisecond {
+		t.Errorf("expected agent crawl delay of 500ms, got %v", delay)
+	}
+}
+
+func TestParseRobots_Sitemaps(t *testing.T) {
+	content := "Sitemap: https://example.com/sitemap.xml\nUser-agent: otherbot\nDisallow: /\nSitemap: https://example.com/news.xml\n"
+	robots := ParseRobots(content, "bot")
+	if len(robots.Sitemaps) != 2 || robots.Sitemaps[1] != "https://example.com/news.xml" {
+		t.Errorf("expected both sitemaps regardless of group, got %v", robots.Sitemaps)
+	}
+	if !robots.Allowed("/") {
+		t.Errorf("expected rules for other agents not to apply")
+	}
+}
+
+func TestRobotsAllowed_Tie(t *testing.T) {
+	robots := ParseRobots("User-agent: *\nDisallow: /page\nAllow: /page\n", "bot")
+	if !robots.Allowed("/page") {
+		t.Errorf("expected Allow to win a tie with Disallow")
+	}
+
+	var missing *Robots
+	if !missing.Allowed("/anything") {
+		t.Errorf("expected nil robots to allow everything")
+	}
+}
+
+func TestFetchRobots(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected bool
+	}{
+		{"found", http.StatusOK, "User-agent: *\nDisallow: /blocked\n", false},
+		{"missing file allows all", http.StatusNotFound, "", true},
+		{"server error disallows all", http.StatusServiceUnavailable, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotAgent string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotAgent = r.Header.Get("User-Agent")
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			robots := fetchRobots(nil, server.URL+"/robots.txt", "test-agent")
+			if got := robots.Allowed("/blocked"); got != tt.expected {
+				t.Errorf("expected Allowed = %v, got %v", tt.expected, got)
+			}
+			if gotAgent != "test-agent" {
+				t.Errorf("expected user agent %q, got %q", "test-agent", gotAgent)
+			}
+		})
+	}
+
+	// Unreachable hosts allow everything
+	if robots := fetchRobots(nil, "http://127.0.0.1:1/robots.txt", "test-agent"); !robots.Allowed("/") {
+		t.Errorf("expected unreachable robots.txt to allow everything")
+	}
+}
+
+func TestRobotsCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, "User-agent: *\nDisallow: /admin\n")
+	}))
+	defer server.Close()
+
+	cache := newRobotsCache("bot", nil)
+	cache.prefetch([]string{server.URL + "/a", server.URL + "/b", "mailto:someone@example.com"}, 2)
+
+	if cache.allowed(server.URL + "/admin/users") {
+		t.Errorf("expected /admin to be disallowed")
+	}
+	if !cache.allowed(server.URL + "/home?q=1") {
+		t.Errorf("expected /home to be allowed")
+	}
+	if !cache.allowed("mailto:someone@example.com") {
+		t.Errorf("expected non-http urls to be allowed")
+	}
+	if requests != 1 {
+		t.Errorf("expected robots.txt to be fetched once, got %d", requests)
+	}
+}
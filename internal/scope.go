@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"fmt"
+	neturl "net/url"
+	"regexp"
+	"strings"
+)
+
+// ScopeOptions decides which pages belong to the site being crawled
+type ScopeOptions struct {
+	Include           []string // crawl only pages matching one of these patterns, empty for all
+	Exclude           []string // never crawl pages matching one of these patterns
+	IncludeSubdomains bool     // treat subdomains of the start host, and the bare or www. host, as internal
+	PathPrefixes      []string // crawl only pages under one of these paths, empty for all
+	ExtraHosts        []string // more hosts treated as internal; *.example.com matches subdomains
+}
+
+// Scope answers whether a url is internal to the site and whether it
+// should be crawled. Patterns are globs, where * matches any run of
+// characters and ? a single one, or regular expressions prefixed with
+// "re:". Globs starting with / match the path and query, other globs the
+// whole url; regular expressions may match anywhere in the url.
+type Scope struct {
+	files      bool   // the start url is a file://, so every local file is internal
+	host       string // lowercase hostname of the start url
+	site       string // host without www., the parent of its subdomains
+	opts       ScopeOptions
+	include    []urlPattern
+	exclude    []urlPattern
+	extraHosts []string
+}
+
+// NewScope builds the scope of a crawl starting at startURL. It fails when
+// a pattern cannot be compiled.
+func NewScope(startURL string, opts ScopeOptions) (*Scope, error) {
+	s := &Scope{opts: opts}
+	if u, err := neturl.Parse(startURL); err == nil {
+		s.files = u.Scheme == "file"
+		s.host = strings.ToLower(u.Hostname())
+		s.site = strings.TrimPrefix(s.host, "www.")
+	}
+	for _, host := range opts.ExtraHosts {
+		s.extraHosts = append(s.extraHosts, strings.ToLower(host))
+	}
+
+	var err error
+	if s.include, err = compilePatterns(opts.Include); err != nil {
+		return nil, err
+	}
+	if s.exclude, err = compilePatterns(opts.Exclude); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// urlPattern is a compiled glob or regular expression
+type urlPattern struct {
+	re       *regexp.Regexp
+	pathOnly bool // matched against the path and query rather than the whole url
+}
+
+// compilePattern compiles a glob, or a regular expression prefixed with re:
+func compilePattern(pattern string) (urlPattern, error) {
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return urlPattern{}, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		return urlPattern{re: re}, nil
+	}
+	return urlPattern{re: globToRegexp(pattern), pathOnly: strings.HasPrefix(pattern, "/")}, nil
+}
+
+func compilePatterns(patterns []string) ([]urlPattern, error) {
+	var compiled []urlPattern
+	for _, pattern := range patterns {
+		p, err := compilePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, p)
+	}
+	return compiled, nil
+}
+
+// match reports whether the pattern matches u
+func (p urlPattern) match(u *neturl.URL) bool {
+	if p.pathOnly {
+		return p.re.MatchString(u.RequestURI())
+	}
+	return p.re.MatchString(u.String())
+}
+
+// globToRegexp anchors a glob to the whole input
+func globToRegexp(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// Internal reports whether rawURL is on the site: the start host, one of
+// the extra hosts, or with IncludeSubdomains a subdomain of the site. When
+// the start url is a local file, every local file is internal.
+func (s *Scope) Internal(rawURL string) bool {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	if s.files && u.Scheme == "file" {
+		return true
+	}
+	if s.host == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == s.host {
+		return true
+	}
+	if s.opts.IncludeSubdomains && (host == s.site || strings.HasSuffix(host, "."+s.site)) {
+		return true
+	}
+	for _, extra := range s.extraHosts {
+		if parent, wildcard := strings.CutPrefix(extra, "*."); wildcard {
+			if strings.HasSuffix(host, "."+parent) {
+				return true
+			}
+		} else if host == extra {
+			return true
+		}
+	}
+	return false
+}
+
+// Crawlable reports whether rawURL is an internal page that passes the
+// path prefixes and include and exclude patterns
+func (s *Scope) Crawlable(rawURL string) bool {
+	if !s.Internal(rawURL) {
+		return false
+	}
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	if len(s.opts.PathPrefixes) > 0 && !s.underPrefix(u.Path) {
+		return false
+	}
+	if len(s.include) > 0 && !matchAny(s.include, u) {
+		return false
+	}
+	return !matchAny(s.exclude, u)
+}
+
+// underPrefix reports whether path is under one of the path prefixes.
+// "/docs/" also covers "/docs" itself.
+func (s *Scope) underPrefix(path string) bool {
+	for _, prefix := range s.opts.PathPrefixes {
+		if strings.HasPrefix(path, prefix) || path == strings.TrimSuffix(prefix, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAny reports whether any of the patterns matches u
+func matchAny(patterns []urlPattern, u *neturl.URL) bool {
+	for _, p := range patterns {
+		if p.match(u) {
+			return true
+		}
+	}
+	return false
+}
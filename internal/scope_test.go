@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestScope_Hosts(t *testing.T) {
+	scope, err := NewScope("https://www.Example.com/", ScopeOptions{
+		IncludeSubdomains: true,
+		ExtraHosts:        []string{"cdn.example.net", "*.example.org"},
+	})
+	if err != nil {
+		t.Fatalf("NewScope() error = %v", err)
+	}
+
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{"https://www.example.com/a", true},
+		{"https://example.com/a", true},         // the bare host of www.
+		{"https://docs.example.com/a", true},    // a subdomain
+		{"https://a.b.example.com/a", true},     // nested subdomains
+		{"https://badexample.com/a", false},     // not a subdomain
+		{"https://cdn.example.net/a.js", true},  // extra host
+		{"https://img.cdn.example.net/", false}, // extra hosts are exact
+		{"https://api.example.org/", true},      // wildcard extra host
+		{"https://example.org/", false},         // the wildcard needs a subdomain
+		{"https://other.com/", false},
+	}
+	for _, tt := range tests {
+		if got := scope.Internal(tt.url); got != tt.expected {
+			t.Errorf("Internal(%q) = %v, expected %v", tt.url, got, tt.expected)
+		}
+	}
+}
+
+func TestScope_Crawlable(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     ScopeOptions
+		url      string
+		expected bool
+	}{
+		{"no rules", ScopeOptions{}, "https://example.com/blog/post", true},
+		{"external", ScopeOptions{}, "https://other.com/", false},
+		{"under prefix", ScopeOptions{PathPrefixes: []string{"/docs/"}}, "https://example.com/docs/intro", true},
+		{"prefix itself", ScopeOptions{PathPrefixes: []string{"/docs/"}}, "https://example.com/docs", true},
+		{"outside prefix", ScopeOptions{PathPrefixes: []string{"/docs/"}}, "https://example.com/blog/", false},
+		{"similar prefix", ScopeOptions{PathPrefixes: []string{"/docs/"}}, "https://example.com/docsearch", false},
+		{"path glob excluded", ScopeOptions{Exclude: []string{"/blog/*"}}, "https://example.com/blog/post", false},
+		{"path glob sees query", ScopeOptions{Exclude: []string{"/search*"}}, "https://example.com/search?q=a", false},
+		{"single character", ScopeOptions{Exclude: []string{"/page?"}}, "https://example.com/page10", true},
+		{"url glob", ScopeOptions{Exclude: []string{"https://example.com/*.pdf"}}, "https://example.com/files/a.pdf", false},
+		{"regex", ScopeOptions{Exclude: []string{`re:/v[0-9]+/`}}, "https://example.com/api/v2/users", false},
+		{"include match", ScopeOptions{Include: []string{"/guide/*", "/faq"}}, "https://example.com/faq", true},
+		{"include miss", ScopeOptions{Include: []string{"/guide/*"}}, "https://example.com/faq", false},
+		{"exclude beats include", ScopeOptions{Include: []string{"/guide/*"}, Exclude: []string{"*/old/*"}}, "https://example.com/guide/old/a", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope, err := NewScope("https://example.com/", tt.opts)
+			if err != nil {
+				t.Fatalf("NewScope() error = %v", err)
+			}
+			if got := scope.Crawlable(tt.url); got != tt.expected {
+				t.Errorf("Crawlable(%q) = %v, expected %v", tt.url, got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := NewScope("https://example.com/", ScopeOptions{Include: []string{"re:("}}); err == nil {
+		t.Errorf("expected an error for an invalid regular expression")
+	}
+}
+
+func TestCrawlSite_Scope(t *testing.T) {
+	server := newSiteServer(map[string][]string{
+		"/":             {"/docs/", "/blog/"},
+		"/docs/":        {"/docs/a", "/docs/old/b"},
+		"/docs/a":       {"/docs/a-child"},
+		"/docs/old/b":   {"/docs/old/b-child"},
+		"/blog/":        {"/blog/post"},
+		"/docs/a-child": {},
+	})
+	defer server.Close()
+
+	scope, _ := NewScope(server.URL, ScopeOptions{PathPrefixes: []string{"/docs/"}, Exclude: []string{"/docs/old/*"}})
+	result := CrawlSite(server.URL+"/", CrawlOptions{MaxDepth: 5, Workers: 2, Scope: scope})
+
+	collected := make(map[string]bool)
+	for _, link := range result.Links {
+		collected[link] = true
+	}
+	// Pages outside the scope are still checked, but not crawled
+	for _, path := range []string{"/docs/", "/blog/", "/docs/a", "/docs/old/b", "/docs/a-child"} {
+		if !collected[server.URL+path] {
+			t.Errorf("expected %s to be collected, got %v", path, result.Links)
+		}
+	}
+	for _, path := range []string{"/blog/post", "/docs/old/b-child"} {
+		if collected[server.URL+path] {
+			t.Errorf("expected %s not to be reached, got %v", path, result.Links)
+		}
+	}
+}
@@ -8,20 +8,33 @@ import (
 
 // Scrape performs a web scraping operation on the given URL.
 func Scrape(url string) (string, error) {
-	resp, err := http.Get(url)
+	return defaultClient.Scrape(url)
+}
+
+// Scrape fetches the body of url using the client's settings. Any
+// response other than 200 OK is an error.
+func (c *Client) Scrape(url string) (string, error) {
+	body, _, err := c.ScrapePage(url)
+	return body, err
+}
+
+// ScrapePage is Scrape that also returns the url the body was served from
+// after following redirects, which relative links in it resolve against.
+func (c *Client) ScrapePage(url string) (body, finalURL string, err error) {
+	resp, err := c.Get(url)
 	if err != nil {
-		return "", fmt.Errorf("HTTP Error: %w", err)
+		return "", "", fmt.Errorf("HTTP Error: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP Error status code %d", resp.StatusCode)
+		return "", "", fmt.Errorf("HTTP Error status code %d", resp.StatusCode)
 	}
 
 	// Read the response body
-	body, err := io.ReadAll(resp.Body)
+	content, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("HTTP Error reading body: %w", err)
+		return "", "", fmt.Errorf("HTTP Error reading body: %w", err)
 	}
-	return string(body), nil
+	return string(content), resp.Request.URL.String(), nil
 }
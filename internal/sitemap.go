@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxSitemaps stops a crawl following an endless chain of sitemap indexes
+const maxSitemaps = 1000
+
+// maxSitemapSize is the largest uncompressed sitemap the protocol allows
+const maxSitemapSize = 50 * 1024 * 1024
+
+// SitemapEntry is a single <loc> found in a sitemap
+type SitemapEntry struct {
+	URL    string
+	Line   int
+	Column int
+}
+
+// Sitemap holds the locations listed in a sitemap or sitemap index
+type Sitemap struct {
+	URLs     []SitemapEntry // pages from a <urlset>
+	Sitemaps []SitemapEntry // child sitemaps from a <sitemapindex>
+}
+
+// ParseSitemap reads a sitemap or sitemap index. Gzipped content is
+// detected from its magic bytes and decompressed first.
+func ParseSitemap(data []byte) (*Sitemap, error) {
+	data, err := gunzipIfNeeded(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sitemap := &Sitemap{}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// Name of the element holding the current <loc>, "url" or "sitemap"
+	parent := ""
+	found := false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Sitemap Error: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "urlset", "sitemapindex":
+			found = true
+		case "url", "sitemap":
+			parent = start.Name.Local
+		case "loc":
+			line, column := decoder.InputPos()
+			var loc string
+			if err := decoder.DecodeElement(&loc, &start); err != nil {
+				return nil, fmt.Errorf("Sitemap Error: %w", err)
+			}
+			loc = strings.TrimSpace(loc)
+			if loc == "" {
+				continue
+			}
+			entry := SitemapEntry{URL: loc, Line: line, Column: column}
+			if parent == "sitemap" {
+				sitemap.Sitemaps = append(sitemap.Sitemaps, entry)
+			} else {
+				sitemap.URLs = append(sitemap.URLs, entry)
+			}
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("Sitemap Error: no <urlset> or <sitemapindex> element")
+	}
+	return sitemap, nil
+}
+
+// gunzipIfNeeded decompresses gzip data and passes anything else through
+func gunzipIfNeeded(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("Sitemap Error decompressing: %w", err)
+	}
+	defer reader.Close()
+
+	data, err = io.ReadAll(io.LimitReader(reader, maxSitemapSize))
+	if err != nil {
+		return nil, fmt.Errorf("Sitemap Error decompressing: %w", err)
+	}
+	return data, nil
+}
+
+// sitemapPage is a page url listed in a sitemap, with where it was listed
+type sitemapPage struct {
+	sitemapURL string
+	entry      SitemapEntry
+}
+
+// collectSitemaps reads every sitemap reachable from the given urls,
+// following sitemap indexes, and returns the page entries in order along
+// with a description of any sitemap that could not be read.
+func collectSitemaps(client *Client, sitemapURLs []string, limiter *RateLimiter) ([]sitemapPage, []string) {
+	var pages []sitemapPage
+	var failures []string
+	seen := make(map[string]bool)
+
+	queue := append([]string{}, sitemapURLs...)
+	for len(queue) > 0 && len(seen) < maxSitemaps {
+		sitemapURL := queue[0]
+		queue = queue[1:]
+		if seen[sitemapURL] {
+			continue
+		}
+		seen[sitemapURL] = true
+
+		limiter.Wait(hostOf(sitemapURL), 0)
+		content, err := client.Scrape(sitemapURL)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", sitemapURL, err))
+			continue
+		}
+		sitemap, err := ParseSitemap([]byte(content))
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", sitemapURL, err))
+			continue
+		}
+
+		for _, entry := range sitemap.URLs {
+			pages = append(pages, sitemapPage{sitemapURL: sitemapURL, entry: entry})
+		}
+		for _, child := range sitemap.Sitemaps {
+			if childURL := resolveURL(child.URL, sitemapURL); childURL != "" {
+				queue = append(queue, childURL)
+			}
+		}
+	}
+	return pages, failures
+}
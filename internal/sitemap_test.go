@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, content string) []byte {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(content)); err != nil {
+		t.Fatalf("gzip write error = %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("gzip close error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+const testURLSet = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/</loc></url>
+  <url>
+    <loc> https://example.com/about </loc>
+    <lastmod>2024-01-01</lastmod>
+  </url>
+  <url><loc></loc></url>
+</urlset>`
+
+func TestParseSitemap(t *testing.T) {
+	t.Run("urlset", func(t *testing.T) {
+		sitemap, err := ParseSitemap([]byte(testURLSet))
+		if err != nil {
+			t.Fatalf("ParseSitemap() error = %v", err)
+		}
+		if len(sitemap.URLs) != 2 || len(sitemap.Sitemaps) != 0 {
+			t.Fatalf("expected 2 urls and no sitemaps, got %+v", sitemap)
+		}
+		if sitemap.URLs[1].URL != "https://example.com/about" || sitemap.URLs[1].Line != 5 {
+			t.Errorf("expected trimmed about url on line 5, got %+v", sitemap.URLs[1])
+		}
+	})
+
+	t.Run("sitemap index", func(t *testing.T) {
+		index := `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/sitemap-posts.xml</loc></sitemap>
+  <sitemap><loc>/sitemap-pages.xml.gz</loc></sitemap>
+</sitemapindex>`
+		sitemap, err := ParseSitemap([]byte(index))
+		if err != nil {
+			t.Fatalf("ParseSitemap() error = %v", err)
+		}
+		if len(sitemap.URLs) != 0 || len(sitemap.Sitemaps) != 2 {
+			t.Fatalf("expected 2 child sitemaps, got %+v", sitemap)
+		}
+		if sitemap.Sitemaps[1].URL != "/sitemap-pages.xml.gz" {
+			t.Errorf("expected relative child sitemap, got %+v", sitemap.Sitemaps[1])
+		}
+	})
+
+	t.Run("gzipped", func(t *testing.T) {
+		sitemap, err := ParseSitemap(gzipBytes(t, testURLSet))
+		if err != nil {
+			t.Fatalf("ParseSitemap() error = %v", err)
+		}
+		if len(sitemap.URLs) != 2 {
+			t.Errorf("expected 2 urls from gzipped sitemap, got %+v", sitemap)
+		}
+	})
+
+	t.Run("not a sitemap", func(t *testing.T) {
+		if _, err := ParseSitemap([]byte("<html><body>Not found</body></html>")); err == nil {
+			t.Errorf("expected error for html document")
+		}
+	})
+
+	t.Run("malformed xml", func(t *testing.T) {
+		if _, err := ParseSitemap([]byte("<urlset><url><loc>https://example.com")); err == nil {
+			t.Errorf("expected error for truncated xml")
+		}
+	})
+
+	t.Run("corrupt gzip", func(t *testing.T) {
+		if _, err := ParseSitemap([]byte{0x1f, 0x8b, 0x00, 0x01}); err == nil {
+			t.Errorf("expected error for corrupt gzip")
+		}
+	})
+}
+
+func TestCollectSitemaps(t *testing.T) {
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<sitemapindex>
+<sitemap><loc>%s/posts.xml.gz</loc></sitemap>
+<sitemap><loc>/pages.xml</loc></sitemap>
+<sitemap><loc>/sitemap.xml</loc></sitemap>
+<sitemap><loc>/missing.xml</loc></sitemap>
+</sitemapindex>`, server.URL)
+	})
+	mux.HandleFunc("/posts.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Write(gzipBytes(t, `<urlset><url><loc>/posts/1</loc></url></urlset>`))
+	})
+	mux.HandleFunc("/pages.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<urlset><url><loc>/pages/a</loc></url><url><loc>/pages/b</loc></url></urlset>`)
+	})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	pages, failures := collectSitemaps(nil, []string{server.URL + "/sitemap.xml"}, nil)
+
+	expected := []string{"/posts/1", "/pages/a", "/pages/b"}
+	if len(pages) != len(expected) {
+		t.Fatalf("expected %d pages, got %+v", len(expected), pages)
+	}
+	for i := range expected {
+		if pages[i].entry.URL != expected[i] {
+			t.Errorf("expected page %d to be %q, got %q", i, expected[i], pages[i].entry.URL)
+		}
+	}
+	if pages[0].sitemapURL != server.URL+"/posts.xml.gz" {
+		t.Errorf("expected page to remember its sitemap, got %q", pages[0].sitemapURL)
+	}
+	// The self reference is skipped and only the missing sitemap fails
+	if len(failures) != 1 || !strings.Contains(failures[0], "/missing.xml") {
+		t.Errorf("expected one failure for the missing sitemap, got %v", failures)
+	}
+}
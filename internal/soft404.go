@@ -0,0 +1,243 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mime"
+	neturl "net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// maxSoft404Body caps how much of a page is read when comparing it with
+// the host's error page
+const maxSoft404Body = 512 << 10
+
+// errorTitle matches titles and headings that announce an error page
+var errorTitle = regexp.MustCompile(`(?i)\b(404|not found|page not found|does ?n[o']t exist|no longer available|page unavailable)\b`)
+
+// Soft404Detector spots pages that answer 200 but are really error pages.
+// It requests a random url that cannot exist on each host, once, and
+// compares links on that host with the error page it got back.
+type Soft404Detector struct {
+	client  *Client
+	limiter *RateLimiter
+
+	mu    sync.Mutex
+	hosts map[string]*errorFingerprint
+}
+
+// errorFingerprint is what a host serves for a url that does not exist
+type errorFingerprint struct {
+	once     sync.Once
+	soft     bool   // the probe came back 2xx instead of 404
+	toRoot   bool   // the probe redirected to the homepage
+	finalURL string // where the probe ended up after redirects
+	title    string
+	words    map[string]bool
+}
+
+func NewSoft404Detector(client *Client, limiter *RateLimiter) *Soft404Detector {
+	return &Soft404Detector{client: client, limiter: limiter, hosts: make(map[string]*errorFingerprint)}
+}
+
+// fingerprint probes the host of rawURL the first time it is seen
+func (d *Soft404Detector) fingerprint(rawURL string) *errorFingerprint {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	key := u.Scheme + "://" + u.Host
+
+	d.mu.Lock()
+	fp, ok := d.hosts[key]
+	if !ok {
+		fp = &errorFingerprint{}
+		d.hosts[key] = fp
+	}
+	d.mu.Unlock()
+
+	fp.once.Do(func() {
+		probe := fmt.Sprintf("%s/%016x-dead-link-checker-probe", key, rand.Uint64())
+		page, err := d.fetch(probe)
+		if err != nil || page.status >= 300 {
+			return
+		}
+		fp.soft = true
+		fp.finalURL = page.finalURL
+		fp.toRoot = redirectsHome(probe, page.finalURL)
+		fp.title = page.title
+		fp.words = page.words
+	})
+	return fp
+}
+
+// soft404Page is the part of a response used for comparisons
+type soft404Page struct {
+	status   int
+	finalURL string
+	html     bool
+	title    string // the <title>, or the first <h1> when there is none
+	words    map[string]bool
+}
+
+// fetch downloads and summarizes a page
+func (d *Soft404Detector) fetch(rawURL string) (*soft404Page, error) {
+	d.limiter.Wait(hostOf(rawURL), 0)
+	resp, err := d.client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	page := &soft404Page{status: resp.StatusCode, finalURL: resp.Request.URL.String()}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	page.html = mediaType == "" || mediaType == "text/html" || mediaType == "application/xhtml+xml"
+	if !page.html || resp.StatusCode >= 300 {
+		return page, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSoft404Body))
+	if err != nil {
+		return nil, err
+	}
+	page.title, page.words = pageText(string(body))
+	return page, nil
+}
+
+// check scores an alive result. The confidence is the strongest of the
+// signals found, from 0.5 for a hint to near 1 for a match with the host's
+// error page; 0 leaves the result unsuspected.
+func (d *Soft404Detector) check(result *LinkResult) {
+	if d == nil || result.Dead || result.Ignored || result.StatusCode < 200 || result.StatusCode >= 300 {
+		return
+	}
+	fp := d.fingerprint(result.URL)
+	if fp == nil {
+		return
+	}
+
+	suspect := func(confidence float64, reason string) {
+		if confidence > result.Soft404 {
+			result.Soft404 = confidence
+			result.Soft404Reason = reason
+		}
+	}
+
+	// Redirects are judged from the chain alone, the homepage tells us nothing
+	if result.Redirected && redirectsHome(result.URL, result.FinalURL) {
+		if fp.toRoot {
+			suspect(0.9, "redirects to the homepage, as missing pages on this host do")
+		} else {
+			suspect(0.6, "redirects to the homepage")
+		}
+		return
+	}
+	if fp.soft && !fp.toRoot && result.Redirected && result.FinalURL == fp.finalURL {
+		suspect(0.95, "redirects to the same page as a missing url")
+		return
+	}
+
+	page, err := d.fetch(result.URL)
+	if err != nil || !page.html || page.status >= 300 {
+		return
+	}
+	if fp.soft && !fp.toRoot {
+		similarity := jaccard(page.words, fp.words)
+		if similarity >= 0.9 {
+			suspect(similarity, "content matches the host's error page")
+		}
+		if page.title != "" && page.title == fp.title && similarity >= 0.6 {
+			suspect(0.8, "title and most of the content match the host's error page")
+		}
+	}
+	if errorTitle.MatchString(page.title) {
+		suspect(0.7, fmt.Sprintf("title is %q", page.title))
+	}
+}
+
+// pageText returns the title of an HTML page, falling back to its first
+// heading, and the set of words in its visible text
+func pageText(body string) (string, map[string]bool) {
+	words := make(map[string]bool)
+	var title, heading string
+	var inTitle, inHeading bool
+	skip := 0
+
+	tokenizer := html.NewTokenizer(strings.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if title == "" {
+				title = heading
+			}
+			return strings.TrimSpace(title), words
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "script", "style", "noscript", "template":
+				skip++
+			case "title":
+				inTitle = true
+			case "h1":
+				inHeading = heading == ""
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "script", "style", "noscript", "template":
+				if skip > 0 {
+					skip--
+				}
+			case "title":
+				inTitle = false
+			case "h1":
+				inHeading = false
+			}
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			text := string(tokenizer.Text())
+			switch {
+			case inTitle:
+				title += text
+			case inHeading:
+				heading += text
+			}
+			for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+				return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+			}) {
+				words[word] = true
+			}
+		}
+	}
+}
+
+// jaccard returns how much two word sets overlap, from 0 to 1
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for word := range a {
+		if b[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// Soft404Results filters results down to the suspected soft 404s
+func Soft404Results(results []LinkResult) []LinkResult {
+	var suspected []LinkResult
+	for _, result := range results {
+		if result.Soft404 > 0 {
+			suspected = append(suspected, result)
+		}
+	}
+	return suspected
+}
@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// errorTemplate is served with 200 for every unknown path by the soft host
+const errorTemplate = `<html><head><title>Acme</title><script>var path = "%s";</script></head>
+<body><nav>Home Products About Contact</nav><h1>Oops</h1><p>We looked everywhere but could not find what you wanted.
+Try the search box or head back to the start.</p><footer>Copyright Acme Widgets Incorporated</footer></body></html>`
+
+func TestSoft404Detector(t *testing.T) {
+	soft := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, "<html><head><title>Acme</title></head><body><h1>Welcome</h1></body></html>")
+		case "/products":
+			fmt.Fprint(w, `<html><head><title>Products</title></head><body><h1>Our products</h1>
+				<p>Widgets, gadgets and gizmos for every occasion, shipped worldwide.</p></body></html>`)
+		case "/report.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			fmt.Fprint(w, "%PDF-1.4")
+		default:
+			fmt.Fprintf(w, errorTemplate, r.URL.Path)
+		}
+	}))
+	defer soft.Close()
+
+	// Missing pages on this host redirect to the homepage
+	var probes int
+	homeRedirects := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/":
+			fmt.Fprint(w, "<html><head><title>Home</title></head></html>")
+		case r.URL.Path == "/gone-title":
+			fmt.Fprint(w, "<html><head><title>404 - Page Not Found</title></head></html>")
+		default:
+			if strings.HasSuffix(r.URL.Path, "-probe") {
+				probes++
+			}
+			http.Redirect(w, r, "/", http.StatusFound)
+		}
+	}))
+	defer homeRedirects.Close()
+
+	urls := []string{
+		soft.URL + "/products",
+		soft.URL + "/missing-page",
+		soft.URL + "/report.pdf",
+		homeRedirects.URL + "/old-page",
+		homeRedirects.URL + "/also-old",
+		homeRedirects.URL + "/gone-title",
+		homeRedirects.URL + "/",
+	}
+	opts := CheckOptions{Concurrency: 4, PerHost: 2, Soft404: NewSoft404Detector(nil, nil)}
+	results := CheckLinks(&urls, opts)
+
+	tests := []struct {
+		confidence float64 // minimum expected, 0 for not suspected
+		reason     string
+	}{
+		{0, ""},
+		{0.9, "error page"},
+		{0, ""},
+		{0.9, "homepage"},
+		{0.9, "homepage"},
+		{0.7, "404 - Page Not Found"},
+		{0, ""},
+	}
+	for i, tt := range tests {
+		result := results[i]
+		if result.Dead {
+			t.Errorf("%s: expected alive, got %+v", result.URL, result)
+		}
+		if tt.confidence == 0 && result.Soft404 != 0 {
+			t.Errorf("%s: expected no suspicion, got %.2f (%s)", result.URL, result.Soft404, result.Soft404Reason)
+		}
+		if tt.confidence > 0 && (result.Soft404 < tt.confidence || !strings.Contains(result.Soft404Reason, tt.reason)) {
+			t.Errorf("%s: expected confidence >= %.2f for %q, got %.2f (%s)", result.URL, tt.confidence, tt.reason, result.Soft404, result.Soft404Reason)
+		}
+	}
+	if probes != 1 {
+		t.Errorf("expected one probe per host, got %d", probes)
+	}
+	if len(Soft404Results(results)) != 4 {
+		t.Errorf("expected 4 suspected soft 404s, got %d", len(Soft404Results(results)))
+	}
+}
+
+func TestPageText(t *testing.T) {
+	title, words := pageText(`<html><head><title> Hello World </title><style>.a{color:red}</style></head>
+		<body><h1>Heading</h1><script>ignored()</script><p>Some text, some MORE text.</p></body></html>`)
+	if title != "Hello World" {
+		t.Errorf("title = %q", title)
+	}
+	for _, word := range []string{"hello", "heading", "some", "more", "text"} {
+		if !words[word] {
+			t.Errorf("expected word %q in %v", word, words)
+		}
+	}
+	if words["ignored"] || words["color"] {
+		t.Errorf("expected script and style to be skipped, got %v", words)
+	}
+
+	// Without a title the first heading stands in
+	if title, _ := pageText("<h1>Not Found</h1><h1>Second</h1>"); title != "Not Found" {
+		t.Errorf("title from heading = %q", title)
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	a := map[string]bool{"a": true, "b": true, "c": true}
+	b := map[string]bool{"b": true, "c": true, "d": true}
+	if got := jaccard(a, b); got != 0.5 {
+		t.Errorf("jaccard() = %v, expected 0.5", got)
+	}
+	if got := jaccard(nil, nil); got != 0 {
+		t.Errorf("jaccard of empty sets = %v, expected 0", got)
+	}
+}
+
+func TestNewReportLink_Soft404(t *testing.T) {
+	result := LinkResult{URL: "https://example.com/careers", StatusCode: 200, Soft404: 0.95, Soft404Reason: "content matches the host's error page"}
+	link := NewReportLink(result, nil, false)
+	if link.Status != "alive" || link.Soft404 == nil || link.Soft404.Confidence != 0.95 {
+		t.Errorf("unexpected report link %+v", link)
+	}
+	if plain := NewReportLink(LinkResult{URL: "https://example.com/"}, nil, false); plain.Soft404 != nil {
+		t.Errorf("expected no soft 404 for an unsuspected link, got %+v", plain.Soft404)
+	}
+}